@@ -0,0 +1,540 @@
+package archiver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cheggaaa/pb/v3"
+)
+
+// The game binary must always be executable, even if the archive was made on
+// a system that doesn't record file modes (zip files from Windows, for
+// example).
+var executables = map[string]bool{
+	"cataclysm-tiles": true,
+}
+
+// Extract extracts the tar.gz or zip archive at file into dir, showing a
+// progress bar while it works. Entries with absolute paths or paths that
+// would end up outside of dir are rejected.
+func Extract(file, dir string) error {
+	return extract(file, dir, true)
+}
+
+// ExtractWithoutLinks works like Extract but returns an error if the archive
+// contains any symlinks or hard links. This is for archives that should never
+// contain links, like world exports.
+func ExtractWithoutLinks(file, dir string) error {
+	return extract(file, dir, false)
+}
+
+func extract(file, dir string, allowLinks bool) error {
+	switch {
+	case strings.HasSuffix(file, ".tar.gz"), strings.HasSuffix(file, ".tgz"):
+		return extractTarGz(file, dir, allowLinks)
+	case strings.HasSuffix(file, ".zip"):
+		return extractZip(file, dir, allowLinks)
+	default:
+		return fmt.Errorf("Do not know how to extract %s, it is not a .tar.gz or .zip file", file)
+	}
+}
+
+func extractTarGz(file, dir string, allowLinks bool) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("Could not open %s: %s", file, err)
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("Could not stat %s: %s", file, err)
+	}
+
+	bar := pb.New64(st.Size())
+	bar.Start()
+	defer bar.Finish()
+
+	gz, err := gzip.NewReader(bar.NewProxyReader(f))
+	if err != nil {
+		return fmt.Errorf("Could not read %s as a gzip file: %s", file, err)
+	}
+	defer gz.Close()
+
+	// Every symlink is checked again once everything is extracted, since a
+	// later entry can change what an earlier link resolves to.
+	var links []string
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("Error reading %s: %s", file, err)
+		}
+
+		target, err := safeJoin(dir, hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		isLink := hdr.Typeflag == tar.TypeSymlink || hdr.Typeflag == tar.TypeLink
+		if isLink && !allowLinks {
+			return fmt.Errorf("The archive at %s contains a link at %s, which is not allowed", file, hdr.Name)
+		}
+
+		// A directory that already exists is fine, but anything else would
+		// be written through a symlink that an earlier entry created.
+		err = checkNoSymlinks(dir, target, hdr.Typeflag != tar.TypeDir)
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, dirMode(hdr.FileInfo().Mode()))
+		case tar.TypeReg, tar.TypeRegA:
+			err = writeFile(target, tr, fileMode(target, hdr.FileInfo().Mode()))
+		case tar.TypeSymlink:
+			err = makeSymlink(dir, target, hdr.Name, hdr.Linkname)
+			links = append(links, target)
+		case tar.TypeLink:
+			err = makeHardLink(dir, target, hdr.Linkname)
+		default:
+			// We don't need device files, fifos, etc. for the game.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return checkLinks(dir, links)
+}
+
+func extractZip(file, dir string, allowLinks bool) error {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return fmt.Errorf("Could not open %s as a zip file: %s", file, err)
+	}
+	defer zr.Close()
+
+	var total int64
+	for _, zf := range zr.File {
+		total += int64(zf.CompressedSize64)
+	}
+	bar := pb.New64(total)
+	bar.Start()
+	defer bar.Finish()
+
+	var links []string
+	for _, zf := range zr.File {
+		target, err := safeJoin(dir, zf.Name)
+		if err != nil {
+			return err
+		}
+
+		mode := zf.Mode()
+		if mode&os.ModeSymlink != 0 && !allowLinks {
+			return fmt.Errorf("The archive at %s contains a link at %s, which is not allowed", file, zf.Name)
+		}
+
+		err = checkNoSymlinks(dir, target, !mode.IsDir())
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case mode.IsDir():
+			err = os.MkdirAll(target, dirMode(mode))
+		case mode&os.ModeSymlink != 0:
+			err = extractZipSymlink(dir, target, zf)
+			links = append(links, target)
+		default:
+			err = extractZipFile(target, zf)
+		}
+		if err != nil {
+			return err
+		}
+
+		bar.Add64(int64(zf.CompressedSize64))
+	}
+
+	return checkLinks(dir, links)
+}
+
+func extractZipFile(target string, zf *zip.File) error {
+	rc, err := zf.Open()
+	if err != nil {
+		return fmt.Errorf("Could not read %s from zip file: %s", zf.Name, err)
+	}
+	defer rc.Close()
+
+	return writeFile(target, rc, fileMode(target, zf.Mode()))
+}
+
+func extractZipSymlink(dir, target string, zf *zip.File) error {
+	rc, err := zf.Open()
+	if err != nil {
+		return fmt.Errorf("Could not read %s from zip file: %s", zf.Name, err)
+	}
+	defer rc.Close()
+
+	link, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return fmt.Errorf("Could not read %s from zip file: %s", zf.Name, err)
+	}
+
+	return makeSymlink(dir, target, zf.Name, string(link))
+}
+
+// safeJoin joins name to dir, returning an error if name is absolute or if
+// the resulting path would be outside of dir.
+func safeJoin(dir, name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("Archive contains an entry with an empty name")
+	}
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") || strings.HasPrefix(name, `\`) {
+		return "", fmt.Errorf("Archive contains an entry with an absolute path: %s", name)
+	}
+	for _, part := range strings.FieldsFunc(name, isSeparator) {
+		if part == ".." {
+			return "", fmt.Errorf("Archive contains an entry with a parent directory (..) in its path: %s", name)
+		}
+	}
+
+	target := filepath.Join(dir, name)
+	if !isWithin(dir, target) {
+		return "", fmt.Errorf("Archive entry %s would be extracted outside of %s", name, dir)
+	}
+
+	return target, nil
+}
+
+// checkNoSymlinks returns an error if any directory between dir and target
+// is a symlink. Archives can contain a symlink to a directory followed by
+// entries under that symlink, so checking the entry's name isn't enough to
+// keep it inside dir. If checkTarget is true then target itself must not be
+// a symlink either, since writing to it would follow the link.
+func checkNoSymlinks(dir, target string, checkTarget bool) error {
+	rel, err := filepath.Rel(dir, target)
+	if err != nil {
+		return fmt.Errorf("Could not get the path of %s relative to %s: %s", target, dir, err)
+	}
+
+	parts := strings.Split(rel, string(filepath.Separator))
+	path := dir
+	for i, part := range parts {
+		if part == "." {
+			continue
+		}
+		path = filepath.Join(path, part)
+		if i == len(parts)-1 && !checkTarget {
+			break
+		}
+
+		info, err := os.Lstat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Nothing under a missing path can be a symlink yet.
+				return nil
+			}
+			return fmt.Errorf("Could not stat %s: %s", path, err)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("Archive entry %s would be extracted through the symlink at %s", rel, path)
+		}
+	}
+
+	return nil
+}
+
+func isSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
+
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func writeFile(target string, r io.Reader, mode os.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return fmt.Errorf("Could not make directory %s: %s", filepath.Dir(target), err)
+	}
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return fmt.Errorf("Could not create file at %s: %s", target, err)
+	}
+
+	_, err = io.Copy(out, r)
+	if err != nil {
+		out.Close()
+		return fmt.Errorf("Could not write to %s: %s", target, err)
+	}
+
+	err = out.Close()
+	if err != nil {
+		return fmt.Errorf("Could not write to %s: %s", target, err)
+	}
+
+	// The mode passed to OpenFile is subject to the umask, so we set it
+	// explicitly to make sure executable bits are preserved.
+	return os.Chmod(target, mode)
+}
+
+// Symlinks are only allowed if they point at something inside of dir.
+func makeSymlink(dir, target, name, link string) error {
+	if filepath.IsAbs(link) {
+		return fmt.Errorf("Archive entry %s is a symlink to an absolute path: %s", name, link)
+	}
+	_, err := resolveLink(dir, filepath.Dir(target), link, 0)
+	if err != nil {
+		return fmt.Errorf("Archive entry %s is a symlink that points outside of %s: %s", name, dir, link)
+	}
+
+	err = os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return fmt.Errorf("Could not make directory %s: %s", filepath.Dir(target), err)
+	}
+
+	err = os.Symlink(link, target)
+	if err != nil {
+		return fmt.Errorf("Could not create symlink at %s: %s", target, err)
+	}
+
+	return nil
+}
+
+// checkLinks resolves each of the symlinks that were extracted now that
+// everything is on disk, and removes any that point outside of dir. A link
+// that was safe when it was created can end up leaving dir once later entries
+// turn the directories it goes through into symlinks.
+func checkLinks(dir string, links []string) error {
+	var bad error
+	for _, l := range links {
+		link, err := os.Readlink(l)
+		if err != nil {
+			return fmt.Errorf("Could not read the symlink at %s: %s", l, err)
+		}
+
+		_, err = resolveLink(dir, filepath.Dir(l), link, 0)
+		if err == nil {
+			continue
+		}
+
+		rmErr := os.Remove(l)
+		if rmErr != nil {
+			return fmt.Errorf("Could not remove the symlink at %s: %s", l, rmErr)
+		}
+		if bad == nil {
+			bad = fmt.Errorf("The symlink at %s points outside of %s once the archive is extracted: %s", l, dir, link)
+		}
+	}
+
+	return bad
+}
+
+// maxLinks is how many symlinks we follow when resolving a link before we
+// give up, which is the same limit Linux uses.
+const maxLinks = 40
+
+// resolveLink resolves link relative to from the way the kernel would,
+// following any symlinks already on disk, and returns an error if the path
+// ever leaves dir. Checking the link's text alone isn't enough, since a link
+// like "a/../x" leaves dir if "a" is a symlink to dir.
+func resolveLink(dir, from, link string, depth int) (string, error) {
+	if depth > maxLinks {
+		return "", fmt.Errorf("too many levels of symlinks")
+	}
+
+	path := from
+	for _, part := range strings.FieldsFunc(link, isSeparator) {
+		switch part {
+		case ".":
+			continue
+		case "..":
+			path = filepath.Dir(path)
+		default:
+			path = filepath.Join(path, part)
+			info, err := os.Lstat(path)
+			if err == nil && info.Mode()&os.ModeSymlink != 0 {
+				next, err := os.Readlink(path)
+				if err != nil {
+					return "", err
+				}
+				if filepath.IsAbs(next) {
+					return "", fmt.Errorf("%s is a symlink to an absolute path", path)
+				}
+				path, err = resolveLink(dir, filepath.Dir(path), next, depth+1)
+				if err != nil {
+					return "", err
+				}
+			}
+		}
+
+		if !isWithin(dir, path) {
+			return "", fmt.Errorf("%s is outside of %s", path, dir)
+		}
+	}
+
+	return path, nil
+}
+
+func makeHardLink(dir, target, link string) error {
+	from, err := safeJoin(dir, link)
+	if err != nil {
+		return err
+	}
+	err = checkNoSymlinks(dir, from, false)
+	if err != nil {
+		return err
+	}
+
+	// A hard link to a symlink is just another copy of the symlink, so it
+	// could point anywhere relative to its new location.
+	info, err := os.Lstat(from)
+	if err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("Archive entry %s is a hard link to %s, which is a symlink", target, link)
+	}
+
+	err = os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return fmt.Errorf("Could not make directory %s: %s", filepath.Dir(target), err)
+	}
+
+	err = os.Link(from, target)
+	if err != nil {
+		return fmt.Errorf("Could not create hard link at %s: %s", target, err)
+	}
+
+	return nil
+}
+
+func fileMode(target string, mode os.FileMode) os.FileMode {
+	perm := mode.Perm()
+	if executables[filepath.Base(target)] {
+		perm |= 0755
+	}
+	if perm == 0 {
+		perm = 0644
+	}
+	return perm
+}
+
+func dirMode(mode os.FileMode) os.FileMode {
+	// We always want to be able to write files into directories we create.
+	return mode.Perm() | 0700
+}
+
+// Create makes a tar.gz archive at file containing dir and everything under
+// it. The entries in the archive are relative to dir's parent, so they all
+// start with dir's base name. The archive is written to a temporary file and
+// renamed into place once it's complete.
+func Create(file, dir string) error {
+	part := file + ".part"
+	out, err := os.Create(part)
+	if err != nil {
+		return fmt.Errorf("Could not create file at %s: %s", part, err)
+	}
+
+	err = writeTarGz(out, dir)
+	if err != nil {
+		out.Close()
+		os.Remove(part)
+		return err
+	}
+
+	err = out.Close()
+	if err != nil {
+		os.Remove(part)
+		return fmt.Errorf("Could not write to %s: %s", part, err)
+	}
+
+	err = os.Rename(part, file)
+	if err != nil {
+		os.Remove(part)
+		return fmt.Errorf("Could not move %s to %s: %s", part, file, err)
+	}
+
+	return nil
+}
+
+func writeTarGz(out io.Writer, dir string) error {
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	base := filepath.Dir(filepath.Clean(dir))
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Could not archive %s: %s", dir, err)
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return fmt.Errorf("Could not archive %s: %s", dir, err)
+	}
+	err = gz.Close()
+	if err != nil {
+		return fmt.Errorf("Could not archive %s: %s", dir, err)
+	}
+
+	return nil
+}
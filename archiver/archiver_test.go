@@ -0,0 +1,312 @@
+package archiver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type entry struct {
+	name string
+	// link is the target of a symlink or hard link.
+	link     string
+	typeflag byte
+	body     string
+}
+
+func dir(name string) entry        { return entry{name: name, typeflag: tar.TypeDir} }
+func file(name, body string) entry { return entry{name: name, typeflag: tar.TypeReg, body: body} }
+func symlink(name, link string) entry {
+	return entry{name: name, link: link, typeflag: tar.TypeSymlink}
+}
+func hardlink(name, link string) entry {
+	return entry{name: name, link: link, typeflag: tar.TypeLink}
+}
+
+func writeTar(t *testing.T, path string, entries []entry) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:     e.name,
+			Linkname: e.link,
+			Typeflag: e.typeflag,
+			Mode:     0644,
+			Size:     int64(len(e.body)),
+		}
+		if e.typeflag == tar.TypeDir {
+			hdr.Mode = 0755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeZip(t *testing.T, path string, entries []entry) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Store}
+		body := e.body
+		switch e.typeflag {
+		case tar.TypeDir:
+			hdr.SetMode(os.ModeDir | 0755)
+		case tar.TypeSymlink:
+			hdr.SetMode(os.ModeSymlink | 0777)
+			body = e.link
+		default:
+			hdr.SetMode(0644)
+		}
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setup returns the directory to extract into. It is inside another
+// temporary directory so that we can check that nothing was written next to
+// it.
+func setup(t *testing.T) (string, string) {
+	t.Helper()
+
+	root, err := ioutil.TempDir("", "archiver-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(root) })
+
+	target := filepath.Join(root, "target")
+	if err := os.Mkdir(target, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	return root, target
+}
+
+func assertNothingOutside(t *testing.T, root string) {
+	t.Helper()
+
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != "target" && !strings.HasPrefix(e.Name(), "archive") {
+			t.Errorf("found %s outside of the target dir", e.Name())
+		}
+	}
+}
+
+// assertNoEscapingLinks checks that every symlink left in dir resolves to
+// somewhere inside of it.
+func assertNoEscapingLinks(t *testing.T, dir string) {
+	t.Helper()
+
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			return err
+		}
+		resolved, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			// Dangling links can't be followed anywhere.
+			return nil
+		}
+		if !isWithin(real, resolved) {
+			t.Errorf("the symlink at %s resolves to %s, which is outside of %s", path, resolved, dir)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractRejectsTraversal(t *testing.T) {
+	tests := map[string][]entry{
+		"parent dir in name":     {file("../evil", "x")},
+		"absolute path":          {file("/tmp/evil", "x")},
+		"symlink outside":        {symlink("link", "../evil")},
+		"absolute symlink":       {symlink("link", "/etc/passwd")},
+		"hard link outside":      {hardlink("link", "../evil")},
+		"write through link":     {symlink("a", "."), symlink("a/b", ".."), file("b/evil", "x")},
+		"file through dir":       {dir("sub"), symlink("d", "sub"), file("d/f", "x")},
+		"dir through link":       {dir("sub"), symlink("d", "sub"), dir("d/inner")},
+		"replace a symlink":      {file("real", "x"), symlink("link", "real"), file("link", "y")},
+		"link through link":      {symlink("a", "."), symlink("y", "a/../evil")},
+		"hard link via link":     {symlink("a", "."), hardlink("h", "a/../evil")},
+		"nested link to root":    {dir("sub"), symlink("sub/up", ".."), symlink("sub/up/escape", "..")},
+		"link made unsafe later": {dir("d/"), symlink("s1", "d/s2/.."), symlink("d/s2", "..")},
+		"hard link to symlink": {
+			dir("a/"), dir("a/b/"), symlink("a/b/l", "../../x"), hardlink("l2", "a/b/l"),
+		},
+	}
+
+	for name, entries := range tests {
+		t.Run(name, func(t *testing.T) {
+			root, target := setup(t)
+
+			archive := filepath.Join(root, "archive.tar.gz")
+			writeTar(t, archive, entries)
+
+			err := Extract(archive, target)
+			if err == nil {
+				t.Errorf("expected an error extracting %s", archive)
+			}
+			assertNothingOutside(t, root)
+			assertNoEscapingLinks(t, target)
+		})
+	}
+}
+
+func TestExtractZipRejectsTraversal(t *testing.T) {
+	tests := map[string][]entry{
+		"parent dir in name":     {file("../evil", "x")},
+		"symlink outside":        {symlink("link", "../evil")},
+		"write through link":     {symlink("a", "."), symlink("a/b", ".."), file("b/evil", "x")},
+		"file through dir":       {dir("sub/"), symlink("d", "sub"), file("d/f", "x")},
+		"link made unsafe later": {dir("d/"), symlink("s1", "d/s2/.."), symlink("d/s2", "..")},
+	}
+
+	for name, entries := range tests {
+		t.Run(name, func(t *testing.T) {
+			root, target := setup(t)
+
+			archive := filepath.Join(root, "archive.zip")
+			writeZip(t, archive, entries)
+
+			err := Extract(archive, target)
+			if err == nil {
+				t.Errorf("expected an error extracting %s", archive)
+			}
+			assertNothingOutside(t, root)
+			assertNoEscapingLinks(t, target)
+		})
+	}
+}
+
+func TestExtract(t *testing.T) {
+	root, target := setup(t)
+
+	archive := filepath.Join(root, "archive.tar.gz")
+	writeTar(t, archive, []entry{
+		dir("game/"),
+		file("game/cataclysm-tiles", "binary"),
+		dir("game/lib/"),
+		file("game/lib/libfoo.so.1", "lib"),
+		symlink("game/lib/libfoo.so", "libfoo.so.1"),
+		symlink("game/lib/up", "../cataclysm-tiles"),
+		hardlink("game/copy", "game/cataclysm-tiles"),
+	})
+
+	err := Extract(archive, target)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(target, "game/lib/libfoo.so"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "lib" {
+		t.Errorf("libfoo.so contains %q, expected %q", content, "lib")
+	}
+
+	info, err := os.Stat(filepath.Join(target, "game/cataclysm-tiles"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0111 == 0 {
+		t.Errorf("the game binary is not executable, mode is %s", info.Mode())
+	}
+
+	if _, err := os.Stat(filepath.Join(target, "game/copy")); err != nil {
+		t.Errorf("hard link was not created: %s", err)
+	}
+}
+
+func TestExtractWithoutLinks(t *testing.T) {
+	tests := map[string][]entry{
+		"symlink":   {file("world/master.gsav", "x"), symlink("world/link", "master.gsav")},
+		"hard link": {file("world/master.gsav", "x"), hardlink("world/link", "world/master.gsav")},
+	}
+
+	for name, entries := range tests {
+		t.Run(name, func(t *testing.T) {
+			root, target := setup(t)
+
+			archive := filepath.Join(root, "archive.tar.gz")
+			writeTar(t, archive, entries)
+
+			err := ExtractWithoutLinks(archive, target)
+			if err == nil {
+				t.Errorf("expected an error extracting %s", archive)
+			}
+		})
+	}
+
+	t.Run("zip symlink", func(t *testing.T) {
+		root, target := setup(t)
+
+		archive := filepath.Join(root, "archive.zip")
+		writeZip(t, archive, []entry{file("world/master.gsav", "x"), symlink("world/link", "master.gsav")})
+
+		err := ExtractWithoutLinks(archive, target)
+		if err == nil {
+			t.Errorf("expected an error extracting %s", archive)
+		}
+	})
+
+	t.Run("no links", func(t *testing.T) {
+		root, target := setup(t)
+
+		archive := filepath.Join(root, "archive.tar.gz")
+		writeTar(t, archive, []entry{dir("world/"), file("world/master.gsav", "x")})
+
+		err := ExtractWithoutLinks(archive, target)
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	})
+}
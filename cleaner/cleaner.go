@@ -1,10 +1,8 @@
 package cleaner
 
 import (
-	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 
 	"github.com/houseabsolute/catalauncher/config"
 	"github.com/houseabsolute/catalauncher/localbuilds"
@@ -51,15 +49,21 @@ func (c *Cleaner) Clean() error {
 	for _, k := range c.keep {
 		shouldKeep[k] = true
 	}
+	pinned := map[uint]bool{}
+	for _, p := range c.config.PinnedBuilds() {
+		pinned[p] = true
+	}
 
 	rest := all[0 : len(all)-c.max]
 
 	for _, b := range rest {
 		if shouldKeep[b] {
 			util.Say(c.stdout, "Keeping build %d as requested", b)
+		} else if pinned[b] {
+			util.Say(c.stdout, "Keeping build %d because it is pinned", b)
 		} else {
 			util.Say(c.stdout, "Deleting build %d", b)
-			err := os.RemoveAll(filepath.Join(c.config.BuildsDir(), fmt.Sprintf("%d", b)))
+			err := os.RemoveAll(c.config.BuildDir(b))
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"github.com/houseabsolute/catalauncher/doctor"
+	"github.com/houseabsolute/catalauncher/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var doctorJSON bool
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that your system can run the game",
+	Long: `
+The doctor subcommand checks everything on your system that the game's
+container uses, like your X11 socket, PulseAudio directories, and GPU device,
+as well as whether the container runtime is installed and reachable. Each
+check passes, warns, or fails, with a hint on how to fix any problems. Pass
+"--json" to get the results as JSON.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		d, err := doctor.New(viper.GetString("root"), doctorJSON)
+		if err != nil {
+			util.PrintErrorAndExit(err.Error())
+		}
+
+		err = d.Diagnose()
+		if err != nil {
+			util.PrintErrorAndExit(err.Error())
+		}
+	},
+}
+
+func init() {
+	doctorCmd.PersistentFlags().BoolVar(
+		&doctorJSON, "json", false, "print the results as JSON")
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"github.com/houseabsolute/catalauncher/config"
+	"github.com/houseabsolute/catalauncher/extras"
+	"github.com/houseabsolute/catalauncher/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// extrasCmd represents the extras command
+var extrasCmd = &cobra.Command{
+	Use:   "extras",
+	Short: "Manage the extras repos",
+	Long: `
+The extras subcommand manages the lockfile that records which commit of each
+extras repo is copied into the game. When the lockfile exists the launcher
+always uses the commits in it, so a broken upstream commit won't reach your
+game until you choose to update.
+`,
+}
+
+var extrasLockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Lock each extras repo to the commit that is currently checked out",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := newExtras().Lock()
+		if err != nil {
+			util.PrintErrorAndExit(err.Error())
+		}
+	},
+}
+
+var extrasUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update each extras repo to its latest commit and lock it there",
+	Long: `
+The update subcommand fetches each extras repo, checks out the latest commit
+on its branch (or the commit or tag it is pinned to in the config file), and
+then writes the lockfile with the new commits. The new extras are copied into
+the game the next time you launch it.
+`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := newExtras().Update()
+		if err != nil {
+			util.PrintErrorAndExit(err.Error())
+		}
+	},
+}
+
+func newExtras() *extras.Extras {
+	c, err := config.New(viper.GetString("root"))
+	if err != nil {
+		util.PrintErrorAndExit(err.Error())
+	}
+
+	e, err := extras.New(c)
+	if err != nil {
+		util.PrintErrorAndExit(err.Error())
+	}
+
+	return e
+}
+
+func init() {
+	extrasCmd.AddCommand(extrasLockCmd)
+	extrasCmd.AddCommand(extrasUpdateCmd)
+	rootCmd.AddCommand(extrasCmd)
+}
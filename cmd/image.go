@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"github.com/houseabsolute/catalauncher/imagebuilder"
+	"github.com/houseabsolute/catalauncher/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var imageTag string
+
+// imageCmd represents the image command
+var imageCmd = &cobra.Command{
+	Use:   "image",
+	Short: "Manage the image the game is run in",
+}
+
+var imageBuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Build the image the game is run in",
+	Long: `
+The build subcommand builds the image the game is run in from the Dockerfile
+that is bundled with the launcher, so you don't need to pull it from Docker
+Hub. By default the image is tagged "` + imagebuilder.DefaultTag + `". Images
+tagged with the "localhost" registry are never pulled when launching the game.
+`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		b, err := imagebuilder.New(viper.GetString("root"), imageTag)
+		if err != nil {
+			util.PrintErrorAndExit(err.Error())
+		}
+
+		err = b.Build()
+		if err != nil {
+			util.PrintErrorAndExit(err.Error())
+		}
+	},
+}
+
+func init() {
+	imageBuildCmd.PersistentFlags().StringVar(
+		&imageTag, "tag", "", "the tag to give the image (defaults to "+imagebuilder.DefaultTag+")")
+	imageCmd.AddCommand(imageBuildCmd)
+	rootCmd.AddCommand(imageCmd)
+}
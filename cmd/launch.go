@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	"errors"
+	"os"
+
 	"github.com/houseabsolute/catalauncher/launcher"
 	"github.com/houseabsolute/catalauncher/util"
 	"github.com/spf13/cobra"
@@ -8,6 +11,7 @@ import (
 )
 
 var build uint
+var offline bool
 
 // launchCmd represents the launch command
 var launchCmd = &cobra.Command{
@@ -17,14 +21,25 @@ var launchCmd = &cobra.Command{
 The launch subcommand will start Cataclysm: DDA in a Docker container, keeping
 your saves and config in a directory on your host machine. By default it
 always downloads the latest build but you can override that with the "--build"
-flag.`,
+flag.
+
+If you pass the "--offline" flag then the launcher will not touch the network
+at all. It launches the newest build you have already downloaded (or the one
+you asked for with "--build"), uses the local copy of the extras repo, and
+does not pull the Docker image. The launcher falls back to offline mode
+automatically if it cannot reach the build source.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		l, err := launcher.New(viper.GetString("root"), build)
+		l, err := launcher.New(viper.GetString("root"), build, offline)
 		if err != nil {
 			util.PrintErrorAndExit(err.Error())
 		}
 
 		err = l.Launch()
+		var exitErr *launcher.ExitError
+		if errors.As(err, &exitErr) {
+			util.Say(os.Stderr, err.Error())
+			os.Exit(exitErr.Code)
+		}
 		if err != nil {
 			util.PrintErrorAndExit(err.Error())
 		}
@@ -34,5 +49,7 @@ flag.`,
 func init() {
 	launchCmd.PersistentFlags().UintVar(
 		&build, "build", 0, "the build number to launch (defaults to the latest)")
+	launchCmd.PersistentFlags().BoolVar(
+		&offline, "offline", false, "launch a local build without using the network")
 	rootCmd.AddCommand(launchCmd)
 }
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"github.com/houseabsolute/catalauncher/lister"
+	"github.com/houseabsolute/catalauncher/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var remote bool
+var listJSON bool
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List installed and available builds",
+	Long: `
+The list subcommand shows the builds you have installed, along with when they
+were released, how much disk space they use, and when you last launched them.
+
+If you pass the "--remote" flag it also shows the builds that are available
+from the build source, marking which are installed, which is the latest, and
+which are pinned. Pass "--json" to get the output as JSON.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		l, err := lister.New(viper.GetString("root"), remote, listJSON)
+		if err != nil {
+			util.PrintErrorAndExit(err.Error())
+		}
+
+		err = l.List()
+		if err != nil {
+			util.PrintErrorAndExit(err.Error())
+		}
+	},
+}
+
+func init() {
+	listCmd.PersistentFlags().BoolVar(
+		&remote, "remote", false, "also list the builds available from the build source")
+	listCmd.PersistentFlags().BoolVar(
+		&listJSON, "json", false, "print the list as JSON")
+	rootCmd.AddCommand(listCmd)
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"github.com/houseabsolute/catalauncher/config"
+	"github.com/houseabsolute/catalauncher/mods"
+	"github.com/houseabsolute/catalauncher/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var modsBuild uint
+var modsClearBuild uint
+
+// modsCmd represents the mods command
+var modsCmd = &cobra.Command{
+	Use:   "mods",
+	Short: "List, enable, and disable mods from the extras",
+	Long: `
+The mods subcommand manages the mods provided by the extras repos. Only the
+mods you enable, along with the mods they depend on, are copied into the game
+when you launch it. Mods that ship with the game are always available.
+`,
+}
+
+var modsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the mods in the game and the extras",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := newMods().List(modsBuild)
+		if err != nil {
+			util.PrintErrorAndExit(err.Error())
+		}
+	},
+}
+
+var modsEnableCmd = &cobra.Command{
+	Use:   "enable <id>...",
+	Short: "Enable mods from the extras",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := newMods().Enable(args)
+		if err != nil {
+			util.PrintErrorAndExit(err.Error())
+		}
+	},
+}
+
+var modsDisableCmd = &cobra.Command{
+	Use:   "disable <id>...",
+	Short: "Disable mods from the extras",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := newMods().Disable(args)
+		if err != nil {
+			util.PrintErrorAndExit(err.Error())
+		}
+	},
+}
+
+var modsCompatCmd = &cobra.Command{
+	Use:   "compat",
+	Short: "Show which mods crashed the game on which builds",
+	Long: `
+The compat subcommand shows how the last session with each mod from the
+extras ended on each build. A session is marked as crashed if the game exited
+with a non-zero status. Since the launcher can't tell which mod crashed the
+game, every mod copied into the game for that session is marked.
+`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := newMods().Compat()
+		if err != nil {
+			util.PrintErrorAndExit(err.Error())
+		}
+	},
+}
+
+var modsClearCmd = &cobra.Command{
+	Use:   "clear <id>...",
+	Short: "Forget whether mods crashed the game",
+	Long: `
+The clear subcommand forgets the recorded results for the given mods, so they
+are no longer warned about or excluded because they crashed the game. Pass
+--build to only forget the results for one build.
+`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := newMods().ClearCompat(args, modsClearBuild)
+		if err != nil {
+			util.PrintErrorAndExit(err.Error())
+		}
+	},
+}
+
+func newMods() *mods.Mods {
+	c, err := config.New(viper.GetString("root"))
+	if err != nil {
+		util.PrintErrorAndExit(err.Error())
+	}
+
+	return mods.New(c)
+}
+
+func init() {
+	modsListCmd.PersistentFlags().UintVar(
+		&modsBuild, "build", 0, "the build whose built in mods are listed (defaults to the latest local build)")
+	modsCmd.AddCommand(modsListCmd)
+	modsCmd.AddCommand(modsEnableCmd)
+	modsCmd.AddCommand(modsDisableCmd)
+	modsClearCmd.PersistentFlags().UintVar(
+		&modsClearBuild, "build", 0, "only forget the results for this build")
+	modsCmd.AddCommand(modsCompatCmd)
+	modsCmd.AddCommand(modsClearCmd)
+	rootCmd.AddCommand(modsCmd)
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/houseabsolute/catalauncher/packs"
+	"github.com/houseabsolute/catalauncher/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// newPacksCmd returns the command for one kind of pack, with list and use
+// subcommands. The tileset and soundpack commands only differ in the kind of
+// pack they work on.
+func newPacksCmd(kind packs.Kind) *cobra.Command {
+	var build uint
+
+	cmd := &cobra.Command{
+		Use:   kind.Name,
+		Short: fmt.Sprintf("List %ss and pick the one the game uses", kind.Name),
+		Long: fmt.Sprintf(`
+The %[1]s subcommand lists the %[1]ss in the game, including the ones copied
+from the extras, and sets the one the game uses in its options, so you don't
+have to pick it in the game for every new build. The %[1]ss are looked for in
+the latest local build unless you pass "--build".
+`, kind.Name),
+	}
+	cmd.PersistentFlags().UintVar(
+		&build, "build", 0, fmt.Sprintf("the build to look for %ss in (defaults to the latest local build)", kind.Name))
+
+	newPacks := func() *packs.Packs {
+		p, err := packs.New(viper.GetString("root"), kind)
+		if err != nil {
+			util.PrintErrorAndExit(err.Error())
+		}
+		return p
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: fmt.Sprintf("List the %ss in the game", kind.Name),
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := newPacks().List(build)
+			if err != nil {
+				util.PrintErrorAndExit(err.Error())
+			}
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "use <name>",
+		Short: fmt.Sprintf("Set the %s the game uses", kind.Name),
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := newPacks().Use(build, args[0])
+			if err != nil {
+				util.PrintErrorAndExit(err.Error())
+			}
+		},
+	})
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newPacksCmd(packs.Tileset))
+	rootCmd.AddCommand(newPacksCmd(packs.Soundpack))
+}
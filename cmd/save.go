@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"github.com/houseabsolute/catalauncher/snapshotter"
+	"github.com/houseabsolute/catalauncher/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// saveCmd represents the save command
+var saveCmd = &cobra.Command{
+	Use:   "save",
+	Short: "Snapshot and restore your saved games",
+	Long: `
+The save subcommand lets you save scum. Use "save snapshot" to take a
+snapshot of one world (or all of them), "save list" to see the snapshots you
+have, and "save restore" to put a world back the way it was.
+`,
+}
+
+var saveSnapshotCmd = &cobra.Command{
+	Use:   "snapshot [world]",
+	Short: "Take a snapshot of a world, or of all worlds",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s := newSnapshotter()
+
+		world := ""
+		if len(args) > 0 {
+			world = args[0]
+		}
+
+		snap, err := s.Snapshot(world)
+		if err != nil {
+			util.PrintErrorAndExit(err.Error())
+		}
+		util.Say(cmd.OutOrStdout(), "Created snapshot %s", snap.Name)
+	},
+}
+
+var saveListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List save snapshots",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := newSnapshotter().List()
+		if err != nil {
+			util.PrintErrorAndExit(err.Error())
+		}
+	},
+}
+
+var saveRestoreCmd = &cobra.Command{
+	Use:   "restore <snapshot>",
+	Short: "Restore a save snapshot",
+	Long: `
+The restore subcommand replaces the world in a snapshot with the snapshot's
+contents. The world's current state is snapshotted first, so you can undo a
+restore. This refuses to run while the game is running.
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := newSnapshotter().Restore(args[0])
+		if err != nil {
+			util.PrintErrorAndExit(err.Error())
+		}
+	},
+}
+
+func newSnapshotter() *snapshotter.Snapshotter {
+	s, err := snapshotter.New(viper.GetString("root"))
+	if err != nil {
+		util.PrintErrorAndExit(err.Error())
+	}
+	return s
+}
+
+func init() {
+	saveCmd.AddCommand(saveSnapshotCmd, saveListCmd, saveRestoreCmd)
+	rootCmd.AddCommand(saveCmd)
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"github.com/houseabsolute/catalauncher/util"
+	"github.com/houseabsolute/catalauncher/verifier"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var verifyBuilds []uint
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify installed builds against their recorded checksums",
+	Long: `
+The verify subcommand checks the files in installed builds against the SHA-256
+checksums recorded when each build was installed. By default it checks every
+installed build but you can check specific builds by passing the "--build"
+flag.
+
+Note that tilesets and soundpacks copied from the extras repo are not
+checked, but any file from the build's archive that they replace will be
+reported as modified.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		v, err := verifier.New(viper.GetString("root"), verifyBuilds)
+		if err != nil {
+			util.PrintErrorAndExit(err.Error())
+		}
+
+		err = v.Verify()
+		if err != nil {
+			util.PrintErrorAndExit(err.Error())
+		}
+	},
+}
+
+func init() {
+	verifyCmd.PersistentFlags().UintSliceVar(
+		&verifyBuilds, "build", []uint{}, "the build(s) to verify (defaults to all builds)")
+	rootCmd.AddCommand(verifyCmd)
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"github.com/houseabsolute/catalauncher/porter"
+	"github.com/houseabsolute/catalauncher/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var exportFile string
+var importName string
+var importForce bool
+
+// worldCmd represents the world command
+var worldCmd = &cobra.Command{
+	Use:   "world",
+	Short: "Export and import worlds",
+	Long: `
+The world subcommand lets you move worlds between machines. Use "world export"
+to save a world to an archive and "world import" to add a world from an
+archive.
+`,
+}
+
+var worldExportCmd = &cobra.Command{
+	Use:   "export <world>",
+	Short: "Export a world to a tar.gz archive",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := newPorter().Export(args[0], exportFile)
+		if err != nil {
+			util.PrintErrorAndExit(err.Error())
+		}
+	},
+}
+
+var worldImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import a world from an archive",
+	Long: `
+The import subcommand adds the world in an archive made by "world export". It
+will not replace an existing world with the same name unless you pass the
+"--force" flag, in which case the existing world is snapshotted first. You can
+import the world under a different name with the "--name" flag.
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := newPorter().Import(args[0], importName, importForce)
+		if err != nil {
+			util.PrintErrorAndExit(err.Error())
+		}
+	},
+}
+
+func newPorter() *porter.Porter {
+	p, err := porter.New(viper.GetString("root"))
+	if err != nil {
+		util.PrintErrorAndExit(err.Error())
+	}
+	return p
+}
+
+func init() {
+	worldExportCmd.Flags().StringVarP(
+		&exportFile, "output", "o", "", "the file to write (defaults to <world>.tar.gz)")
+	worldImportCmd.Flags().StringVar(
+		&importName, "name", "", "import the world under this name")
+	worldImportCmd.Flags().BoolVar(
+		&importForce, "force", false, "replace an existing world with the same name")
+	worldCmd.AddCommand(worldExportCmd, worldImportCmd)
+	rootCmd.AddCommand(worldCmd)
+}
@@ -3,13 +3,17 @@ package config
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/spf13/viper"
 )
 
 type Config struct {
-	rootDir string
-	gameDir string
+	rootDir  string
+	gameDirs map[uint]string
 }
 
 func New(rootDir string) (*Config, error) {
@@ -24,6 +28,54 @@ func (c *Config) GameDataDir() string {
 	return filepath.Join(c.RootDir(), "game-data")
 }
 
+// SaveDir returns the directory the game keeps its saved worlds in.
+func (c *Config) SaveDir() string {
+	return filepath.Join(c.GameDataDir(), "save")
+}
+
+// GameConfigDir returns the directory the game keeps its config files in.
+func (c *Config) GameConfigDir() string {
+	return filepath.Join(c.GameDataDir(), "config")
+}
+
+// OptionsFile returns the path of the game's options file.
+func (c *Config) OptionsFile() string {
+	return filepath.Join(c.GameConfigDir(), "options.json")
+}
+
+// SnapshotsDir returns the directory that save snapshots are stored in.
+func (c *Config) SnapshotsDir() string {
+	return filepath.Join(c.RootDir(), "snapshots")
+}
+
+// AutoBackupsDir returns the directory that the snapshots taken before each
+// launch are stored in.
+func (c *Config) AutoBackupsDir() string {
+	return filepath.Join(c.SnapshotsDir(), "auto")
+}
+
+// DefaultSaveBackups is the default number of automatic save backups to keep.
+const DefaultSaveBackups = 10
+
+// SaveBackups returns the number of automatic save backups to keep. This
+// comes from the "backups" key in the "[saves]" section of the config file. A
+// value of 0 turns automatic backups off.
+func (c *Config) SaveBackups() int {
+	if !viper.IsSet("saves.backups") {
+		return DefaultSaveBackups
+	}
+	n := viper.GetInt("saves.backups")
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// LogsDir returns the directory that a log of each launch is written to.
+func (c *Config) LogsDir() string {
+	return filepath.Join(c.RootDir(), "logs")
+}
+
 func (c *Config) ExtrasDir() string {
 	return filepath.Join(c.RootDir(), "extras")
 }
@@ -32,30 +84,202 @@ func (c *Config) BuildsDir() string {
 	return filepath.Join(c.RootDir(), "builds")
 }
 
-func (c *Config) GameDir(num uint) string {
-	if c.gameDir != "" {
-		return c.gameDir
+// CacheDir returns the directory that downloaded build archives are kept in.
+func (c *Config) CacheDir() string {
+	return filepath.Join(c.RootDir(), "cache")
+}
+
+// DefaultCacheMaxMB is the default cap on the size of the CacheDir.
+const DefaultCacheMaxMB = 2048
+
+// CacheMaxBytes returns the maximum total size of the archives kept in the
+// CacheDir. This comes from the "max_mb" key in the "[cache]" section of the
+// config file. A value of 0 means there is no limit.
+func (c *Config) CacheMaxBytes() int64 {
+	mb := int64(DefaultCacheMaxMB)
+	if viper.IsSet("cache.max_mb") {
+		mb = viper.GetInt64("cache.max_mb")
+	}
+	if mb < 0 {
+		mb = 0
+	}
+	return mb * 1024 * 1024
+}
+
+// BuildDir returns the directory that the given build is installed in.
+func (c *Config) BuildDir(num uint) string {
+	return filepath.Join(c.BuildsDir(), fmt.Sprintf("%d", num))
+}
+
+// StagingDir returns the directory that the given build is extracted into
+// before it is moved to its BuildDir.
+func (c *Config) StagingDir(num uint) string {
+	return filepath.Join(c.BuildsDir(), fmt.Sprintf("%s%d", StagingPrefix, num))
+}
+
+// StagingPrefix is the prefix for staging directories under the BuildsDir.
+const StagingPrefix = ".staging-"
+
+// PinnedBuilds returns the builds listed in the "pinned" key of the config
+// file. Pinned builds are never deleted by the clean subcommand.
+func (c *Config) PinnedBuilds() []uint {
+	pinned := []uint{}
+	for _, p := range viper.GetIntSlice("pinned") {
+		if p > 0 {
+			pinned = append(pinned, uint(p))
+		}
+	}
+	return pinned
+}
+
+// ContainerRuntime returns the name of the container runtime to run the game
+// with, from the "runtime" key in the config file. If this is empty then the
+// runtime is detected by looking in the $PATH. If this is NativeRuntime then
+// the game is run directly on the host instead of in a container.
+func (c *Config) ContainerRuntime() string {
+	return viper.GetString("runtime")
+}
+
+// DefaultStopTimeout is how long we give the game to exit by default when
+// the launcher is interrupted.
+const DefaultStopTimeout = 30 * time.Second
+
+// StopTimeout returns how long to give the game to exit after the launcher
+// is interrupted before it is killed. This comes from the "stop_timeout" key
+// in the config file, in seconds.
+func (c *Config) StopTimeout() time.Duration {
+	if !viper.IsSet("stop_timeout") {
+		return DefaultStopTimeout
+	}
+	secs := viper.GetInt("stop_timeout")
+	if secs < 0 {
+		secs = 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// NativeRuntime is the "runtime" for running the game without a container.
+const NativeRuntime = "native"
+
+// Native returns true if the game should be run directly on the host.
+func (c *Config) Native() bool {
+	return strings.ToLower(c.ContainerRuntime()) == NativeRuntime
+}
+
+// BuildSource returns the type of build source to use, either "github" or
+// "jenkins". This comes from the "type" key in the "[source]" section of the
+// config file.
+func (c *Config) BuildSource() string {
+	t := viper.GetString("source.type")
+	if t == "" {
+		return "github"
+	}
+	return strings.ToLower(t)
+}
+
+// BuildSourceURL returns the base URL for the build source. If this is empty
+// then the source uses its own default.
+func (c *Config) BuildSourceURL() string {
+	return viper.GetString("source.url")
+}
+
+// BuildSourceRepo returns the GitHub repo (as "owner/name") to look for
+// releases in. If this is empty then the source uses its own default.
+func (c *Config) BuildSourceRepo() string {
+	return viper.GetString("source.repo")
+}
+
+// DefaultImage is the image the game is run in by default.
+const DefaultImage = "houseabsolute/catalauncher-player:latest"
+
+// Image returns the image to run the game in. This comes from the "name" key
+// in the "[image]" section of the config file.
+func (c *Config) Image() string {
+	image := viper.GetString("image.name")
+	if image == "" {
+		return DefaultImage
+	}
+	return image
+}
+
+// UsePinnedImage returns true if each build should be run with the image it
+// last exited cleanly with rather than the configured image. This comes from
+// the "use_pinned" key in the "[image]" section of the config file.
+func (c *Config) UsePinnedImage() bool {
+	return viper.GetBool("image.use_pinned")
+}
+
+// IncludedMounts returns the names of host resources that should always be
+// passed to the game's container, even if they were not detected on the
+// host. This comes from the "include" key in the "[mounts]" section of the
+// config file.
+func (c *Config) IncludedMounts() []string {
+	return viper.GetStringSlice("mounts.include")
+}
+
+// ExcludedMounts returns the names of host resources that should never be
+// passed to the game's container. This comes from the "exclude" key in the
+// "[mounts]" section of the config file.
+func (c *Config) ExcludedMounts() []string {
+	return viper.GetStringSlice("mounts.exclude")
+}
+
+// ForceX11 returns true if the game should use X11 (via XWayland in a Wayland
+// session) even when Wayland is available. This comes from the "force_x11"
+// key in the "[display]" section of the config file.
+func (c *Config) ForceX11() bool {
+	return viper.GetBool("display.force_x11")
+}
+
+// GameBinary is the name of the game's executable.
+const GameBinary = "cataclysm-tiles"
+
+// GameDir returns the directory containing the game for the given build. The
+// archive for each build contains a single directory like
+// "cataclysmdda-0.E" (the version changes over time), so we find it by
+// looking for the game binary.
+func (c *Config) GameDir(num uint) (string, error) {
+	if dir, ok := c.gameDirs[num]; ok {
+		return dir, nil
+	}
+
+	dir, err := FindGameDir(c.BuildDir(num))
+	if err != nil {
+		return "", err
 	}
 
-	root := filepath.Join(c.BuildsDir(), fmt.Sprintf("%d", num))
+	if c.gameDirs == nil {
+		c.gameDirs = map[uint]string{}
+	}
+	c.gameDirs[num] = dir
+
+	return dir, nil
+}
+
+// FindGameDir looks for the directory under root that contains the game
+// binary.
+func FindGameDir(root string) (string, error) {
 	entries, err := ioutil.ReadDir(root)
 	if err != nil {
-		// XXX - This should be returned but there's a bunch of places to
-		// change.
-		panic(err)
+		return "", fmt.Errorf("Could not read directory at %s: %s", root, err)
 	}
-	var dir string
+
 	for _, e := range entries {
-		if strings.HasPrefix(e.Name(), "cataclysmdda-0.E") {
-			dir = e.Name()
-			break
+		if !e.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, e.Name())
+		st, err := os.Stat(filepath.Join(dir, GameBinary))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return "", err
+		}
+		if st.Mode().IsRegular() && st.Mode().Perm()&0111 != 0 {
+			return dir, nil
 		}
 	}
-	if dir == "" {
-		panic(fmt.Sprintf("Could not find cataclysmdda-? dir in %s", root))
-	}
-
-	c.gameDir = filepath.Join(root, dir)
 
-	return c.gameDir
+	return "", fmt.Errorf("Could not find a directory containing an executable %s in %s", GameBinary, root)
 }
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ExtrasSource is a git repo containing extras like tilesets and soundpacks
+// to copy into each build's game directory.
+type ExtrasSource struct {
+	// URL is the URL of the git repo.
+	URL string `mapstructure:"url"`
+	// Branch is the branch to check out. If this is empty the repo's
+	// default branch is used.
+	Branch string `mapstructure:"branch"`
+	// Ref is a commit or tag to pin the source to. This takes precedence
+	// over Branch.
+	Ref string `mapstructure:"ref"`
+	// Path is where the repo is cloned, relative to the extras dir. This
+	// defaults to the last part of the URL without any ".git" suffix.
+	Path string `mapstructure:"path"`
+	// Layout says which directories in the repo are copied to where in the
+	// game directory.
+	Layout []ExtrasMapping `mapstructure:"layout"`
+	// Mods is the directory in the repo containing mods. Mods are not
+	// copied by the layout. Instead only enabled mods are copied.
+	Mods string `mapstructure:"mods"`
+}
+
+// ExtrasMapping maps a directory in an extras repo to a directory in the
+// game directory. Every entry in the From directory is copied into the To
+// directory.
+type ExtrasMapping struct {
+	From string `mapstructure:"from"`
+	To   string `mapstructure:"to"`
+}
+
+// DefaultExtrasURL is the extras repo used when the config file doesn't list
+// any extras sources.
+const DefaultExtrasURL = "https://github.com/houseabsolute/cataclysm-extras-collection.git"
+
+// DefaultExtrasLayout is the layout used for sources that don't have one.
+// This is how the default extras repo is laid out.
+var DefaultExtrasLayout = []ExtrasMapping{
+	{From: "gfx", To: "gfx"},
+	{From: "soundpacks", To: "data/sound"},
+}
+
+// DefaultExtrasMods is the mods directory used for sources that have neither
+// a layout nor a mods directory. This is where the default extras repo keeps
+// its mods.
+const DefaultExtrasMods = "mods"
+
+// ExtrasSources returns the extras sources from the "[[extras]]" tables in
+// the config file, in the order they should be processed. If there are none
+// then the default extras repo is returned.
+func (c *Config) ExtrasSources() ([]ExtrasSource, error) {
+	sources := []ExtrasSource{}
+	if viper.IsSet("extras") {
+		err := viper.UnmarshalKey("extras", &sources)
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse the [[extras]] sections of the config file: %s", err)
+		}
+	} else {
+		sources = append(sources, ExtrasSource{URL: DefaultExtrasURL})
+	}
+
+	paths := map[string]string{}
+	for i := range sources {
+		s := &sources[i]
+		if s.URL == "" {
+			return nil, fmt.Errorf("Extras source #%d in the config file does not have a url", i+1)
+		}
+
+		if s.Path == "" {
+			s.Path = strings.TrimSuffix(path.Base(strings.TrimRight(s.URL, "/")), ".git")
+		}
+		if !isRelativeSubpath(s.Path) {
+			return nil, fmt.Errorf("The path for the %s extras source must be a relative path without any \"..\" in it", s.URL)
+		}
+		if other, ok := paths[s.Path]; ok {
+			return nil, fmt.Errorf(
+				"The %s and %s extras sources would both be cloned to %s, so give one of them a different path",
+				other, s.URL, s.Path,
+			)
+		}
+		paths[s.Path] = s.URL
+
+		if len(s.Layout) == 0 {
+			s.Layout = DefaultExtrasLayout
+			if s.Mods == "" {
+				s.Mods = DefaultExtrasMods
+			}
+		}
+		if s.Mods != "" && !isRelativeSubpath(s.Mods) {
+			return nil, fmt.Errorf(
+				"The mods directory for the %s extras source must be a relative path without any \"..\" in it",
+				s.URL,
+			)
+		}
+		for _, m := range s.Layout {
+			if !isRelativeSubpath(m.From) || !isRelativeSubpath(m.To) {
+				return nil, fmt.Errorf(
+					"The layout for the %s extras source must only contain relative paths without any \"..\" in them",
+					s.URL,
+				)
+			}
+		}
+	}
+
+	return sources, nil
+}
+
+// ExtrasLockFile returns the path of the lockfile that records the commit to
+// use for each extras source.
+func (c *Config) ExtrasLockFile() string {
+	return filepath.Join(c.RootDir(), "extras.lock")
+}
+
+// EnabledModsFile returns the path of the file that records which mods are
+// enabled.
+func (c *Config) EnabledModsFile() string {
+	return filepath.Join(c.RootDir(), "mods.json")
+}
+
+// ModCompatFile returns the path of the file that records whether the game
+// crashed the last time each mod was used with each build.
+func (c *Config) ModCompatFile() string {
+	return filepath.Join(c.RootDir(), "mod-compat.json")
+}
+
+// ExcludeCrashingMods returns true if mods that crashed the game the last
+// time they were used with a build should not be copied into that build.
+// This comes from the "exclude_crashing" key in the "[mods]" section of the
+// config file.
+func (c *Config) ExcludeCrashingMods() bool {
+	return viper.GetBool("mods.exclude_crashing")
+}
+
+// ExtrasSourceDir returns the directory the source is cloned to.
+func (c *Config) ExtrasSourceDir(s ExtrasSource) string {
+	return filepath.Join(c.ExtrasDir(), s.Path)
+}
+
+func isRelativeSubpath(p string) bool {
+	if p == "" || filepath.IsAbs(p) {
+		return false
+	}
+	for _, elt := range strings.Split(filepath.ToSlash(p), "/") {
+		if elt == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,255 @@
+package container
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Mount is a host path that is made available in the container.
+type Mount struct {
+	Host      string
+	Container string
+}
+
+// GameContainerName returns the name we give the game's container. This is
+// the same every time for a given user so that a container left behind by a
+// previous launch can be found.
+func GameContainerName(uid string) string {
+	return "catalauncher-player-" + uid
+}
+
+// RunSpec describes how to run the game in a container.
+type RunSpec struct {
+	// Name is the name to give the container.
+	Name string
+	// UID and GID are the ids the game should run as, so that files it
+	// creates are owned by the user running the launcher.
+	UID string
+	GID string
+	// Env is a list of environment variables to pass to the container. Each
+	// entry is either a name, in which case the value is taken from the host,
+	// or "NAME=value".
+	Env     []string
+	Devices []string
+	Mounts  []Mount
+	Workdir string
+	Image   string
+	Command []string
+}
+
+// Runtime is a container runtime that we can run the game with.
+type Runtime interface {
+	// Name is the name of the runtime as it appears in the config file.
+	Name() string
+	// Executable is the name of the runtime's command line tool.
+	Executable() string
+	// PullArgs returns the arguments to pull the given image.
+	PullArgs(image string) []string
+	// RunArgs returns the arguments to run a container as described by the
+	// spec.
+	RunArgs(spec RunSpec) []string
+	// StateArgs returns the arguments to print the state of the named
+	// container, like "running" or "exited".
+	StateArgs(name string) []string
+	// StopArgs returns the arguments to stop the named container, killing it
+	// if it hasn't stopped after the timeout.
+	StopArgs(name string, timeout time.Duration) []string
+	// RemoveArgs returns the arguments to forcibly remove the named
+	// container.
+	RemoveArgs(name string) []string
+	// ImageIDArgs returns the arguments to print the id of the given image.
+	ImageIDArgs(image string) []string
+	// RepoDigestsArgs returns the arguments to print the repo digests of the
+	// given image as a JSON array.
+	RepoDigestsArgs(image string) []string
+	// BuildArgs returns the arguments to build an image with the given tag
+	// from the Dockerfile in dir.
+	BuildArgs(tag, dir string) []string
+}
+
+// Names are the names of all the runtimes we support, in order of
+// preference.
+var Names = []string{"docker", "podman"}
+
+// New returns the runtime with the given name. If name is empty then the
+// first runtime found in the $PATH is used.
+func New(name string) (Runtime, error) {
+	if name == "" {
+		found := Detect()
+		if len(found) == 0 {
+			return nil, fmt.Errorf(
+				"Could not find a container runtime in your $PATH, looked for %s", strings.Join(Names, " and "))
+		}
+		name = found[0]
+	}
+
+	switch strings.ToLower(name) {
+	case "docker":
+		return &Docker{}, nil
+	case "podman":
+		return &Podman{}, nil
+	default:
+		return nil, fmt.Errorf(
+			`Unknown container runtime "%s", must be one of %s`, name, strings.Join(Names, " or "))
+	}
+}
+
+// Detect returns the names of all the runtimes whose executables are in the
+// $PATH.
+func Detect() []string {
+	found := []string{}
+	for _, n := range Names {
+		if _, err := exec.LookPath(n); err == nil {
+			found = append(found, n)
+		}
+	}
+	return found
+}
+
+// IsRunning returns true if the named container is running. If the runtime
+// isn't installed then nothing can be running.
+func IsRunning(rt Runtime, name string) (bool, error) {
+	_, err := exec.LookPath(rt.Executable())
+	if err != nil {
+		return false, nil
+	}
+
+	state, err := State(rt, name)
+	if err != nil {
+		return false, err
+	}
+
+	return state == "running", nil
+}
+
+// ImageID returns the id of the given image. It returns an error if the
+// image does not exist locally.
+func ImageID(rt Runtime, image string) (string, error) {
+	args := rt.ImageIDArgs(image)
+	out, err := exec.Command(rt.Executable(), args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("Could not run \"%s %s\": %s", rt.Executable(), strings.Join(args, " "), err)
+	}
+
+	// Docker prefixes ids with "sha256:" but Podman doesn't. Both accept the
+	// bare id wherever an image name is expected.
+	return strings.TrimPrefix(strings.TrimSpace(string(out)), "sha256:"), nil
+}
+
+// RepoDigest returns the repo digest of the image, like
+// "houseabsolute/catalauncher-player@sha256:...". Unlike an id, this can be
+// used to pull the same image again later. Images that were built locally
+// and never pushed have no repo digest, in which case this returns an empty
+// string.
+func RepoDigest(rt Runtime, image string) (string, error) {
+	args := rt.RepoDigestsArgs(image)
+	out, err := exec.Command(rt.Executable(), args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("Could not run \"%s %s\": %s", rt.Executable(), strings.Join(args, " "), err)
+	}
+
+	var digests []string
+	err = json.Unmarshal(out, &digests)
+	if err != nil {
+		return "", fmt.Errorf("Could not parse the repo digests of the %s image: %s", image, err)
+	}
+	if len(digests) == 0 {
+		return "", nil
+	}
+
+	// An image can have a digest for each repo it was pulled from, so we
+	// prefer the one for the repo we were asked about.
+	repo := repoName(image)
+	for _, d := range digests {
+		if repoName(d) == repo || strings.HasSuffix(repoName(d), "/"+repo) {
+			return d, nil
+		}
+	}
+
+	return digests[0], nil
+}
+
+// repoName strips the tag or digest from an image name.
+func repoName(image string) string {
+	if i := strings.Index(image, "@"); i != -1 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
+
+// IsRepoDigest returns true if the image is a repo digest rather than a name
+// or id.
+func IsRepoDigest(image string) bool {
+	return strings.Contains(image, "@sha256:")
+}
+
+var imageIDRE = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// IsImageID returns true if the image is an image id rather than a name.
+func IsImageID(image string) bool {
+	return imageIDRE.MatchString(image)
+}
+
+// IsLocal returns true if the image's registry is "localhost". These images
+// were built locally, so there is nothing to pull.
+func IsLocal(image string) bool {
+	return strings.HasPrefix(image, "localhost/")
+}
+
+// State returns the state of the named container, like "running" or
+// "exited". If there is no such container it returns an empty string.
+func State(rt Runtime, name string) (string, error) {
+	args := rt.StateArgs(name)
+	cmd := exec.Command(rt.Executable(), args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// Both runtimes exit non-zero when there's no such container, so we
+		// have to look at the error message to tell that apart from a real
+		// failure.
+		msg := strings.ToLower(stderr.String())
+		if strings.Contains(msg, "no such container") || strings.Contains(msg, "no such object") {
+			return "", nil
+		}
+		return "", fmt.Errorf(
+			"Could not run \"%s %s\": %s\n%s",
+			rt.Executable(), strings.Join(args, " "), err, stderr.String(),
+		)
+	}
+
+	return strings.ToLower(strings.TrimSpace(string(out))), nil
+}
+
+// commonRunArgs returns the arguments that are the same for every runtime.
+func commonRunArgs(spec RunSpec) []string {
+	args := []string{}
+	if spec.Name != "" {
+		args = append(args, "--name", spec.Name)
+	}
+	for _, e := range spec.Env {
+		args = append(args, "-e", e)
+	}
+	for _, d := range spec.Devices {
+		args = append(args, "--device", d)
+	}
+	for _, m := range spec.Mounts {
+		args = append(args, "-v", m.Host+":"+m.Container)
+	}
+	if spec.Workdir != "" {
+		args = append(args, "-w", spec.Workdir)
+	}
+	return args
+}
+
+func timeoutSeconds(timeout time.Duration) string {
+	return fmt.Sprintf("%d", int(timeout.Seconds()))
+}
@@ -0,0 +1,58 @@
+package container
+
+import (
+	"fmt"
+	"time"
+)
+
+type Docker struct{}
+
+func (d *Docker) Name() string {
+	return "docker"
+}
+
+func (d *Docker) Executable() string {
+	return "docker"
+}
+
+func (d *Docker) PullArgs(image string) []string {
+	return []string{"pull", image}
+}
+
+func (d *Docker) RunArgs(spec RunSpec) []string {
+	args := []string{
+		"run",
+		// We don't want the container sticking around once the game exits.
+		"--rm",
+		// We want to make sure save files and such are owned by the current
+		// user, not root.
+		"--user", fmt.Sprintf("%s:%s", spec.UID, spec.GID),
+	}
+	args = append(args, commonRunArgs(spec)...)
+	args = append(args, spec.Image)
+	return append(args, spec.Command...)
+}
+
+func (d *Docker) StateArgs(name string) []string {
+	return []string{"container", "inspect", "--format", "{{.State.Status}}", name}
+}
+
+func (d *Docker) StopArgs(name string, timeout time.Duration) []string {
+	return []string{"stop", "--time", timeoutSeconds(timeout), name}
+}
+
+func (d *Docker) RemoveArgs(name string) []string {
+	return []string{"rm", "--force", name}
+}
+
+func (d *Docker) ImageIDArgs(image string) []string {
+	return []string{"image", "inspect", "--format", "{{.Id}}", image}
+}
+
+func (d *Docker) RepoDigestsArgs(image string) []string {
+	return []string{"image", "inspect", "--format", "{{json .RepoDigests}}", image}
+}
+
+func (d *Docker) BuildArgs(tag, dir string) []string {
+	return []string{"build", "--tag", tag, dir}
+}
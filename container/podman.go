@@ -0,0 +1,81 @@
+package container
+
+import (
+	"strings"
+	"time"
+)
+
+// Podman runs the game with rootless Podman.
+type Podman struct{}
+
+func (p *Podman) Name() string {
+	return "podman"
+}
+
+func (p *Podman) Executable() string {
+	return "podman"
+}
+
+func (p *Podman) PullArgs(image string) []string {
+	return []string{"pull", qualify(image)}
+}
+
+func (p *Podman) RunArgs(spec RunSpec) []string {
+	args := []string{
+		"run",
+		// We don't want the container sticking around once the game exits.
+		"--rm",
+		// With rootless Podman, passing --user would give us a uid that is
+		// mapped to some subordinate id on the host. keep-id maps our own uid
+		// and gid into the container instead, so save files are owned by the
+		// current user.
+		"--userns=keep-id",
+		// SELinux labeling would stop the game from using the X11 and
+		// PulseAudio sockets we mount.
+		"--security-opt", "label=disable",
+	}
+	args = append(args, commonRunArgs(spec)...)
+	args = append(args, qualify(spec.Image))
+	return append(args, spec.Command...)
+}
+
+// Podman may prompt for (or refuse to resolve) short image names, so we
+// qualify names without a registry with docker.io, which is where Docker
+// would look. Image ids are left alone.
+func qualify(image string) string {
+	if IsImageID(image) {
+		return image
+	}
+	first := strings.SplitN(image, "/", 2)[0]
+	if strings.Contains(image, "/") && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return image
+	}
+	return "docker.io/" + image
+}
+
+// The state shown by "podman ps" is human-readable, like "Up 5 minutes", on
+// some versions of Podman, but the inspect status is always a single word like
+// "running".
+func (p *Podman) StateArgs(name string) []string {
+	return []string{"container", "inspect", "--format", "{{.State.Status}}", name}
+}
+
+func (p *Podman) StopArgs(name string, timeout time.Duration) []string {
+	return []string{"stop", "--time", timeoutSeconds(timeout), name}
+}
+
+func (p *Podman) RemoveArgs(name string) []string {
+	return []string{"rm", "--force", name}
+}
+
+func (p *Podman) ImageIDArgs(image string) []string {
+	return []string{"image", "inspect", "--format", "{{.Id}}", qualify(image)}
+}
+
+func (p *Podman) RepoDigestsArgs(image string) []string {
+	return []string{"image", "inspect", "--format", "{{json .RepoDigests}}", qualify(image)}
+}
+
+func (p *Podman) BuildArgs(tag, dir string) []string {
+	return []string{"build", "--tag", qualify(tag), dir}
+}
@@ -0,0 +1,13 @@
+// Package docker holds the Dockerfile for the image the game is run in, so
+// that the launcher can build the image without a copy of this repo.
+package docker
+
+import (
+	// This is needed for go:embed.
+	_ "embed"
+)
+
+// Dockerfile is the contents of the Dockerfile in this directory.
+//
+//go:embed Dockerfile
+var Dockerfile []byte
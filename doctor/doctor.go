@@ -0,0 +1,363 @@
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/houseabsolute/catalauncher/config"
+	"github.com/houseabsolute/catalauncher/container"
+	"github.com/houseabsolute/catalauncher/curuser"
+	"github.com/houseabsolute/catalauncher/hostenv"
+	"github.com/houseabsolute/catalauncher/libcheck"
+	"github.com/houseabsolute/catalauncher/localbuilds"
+	"github.com/houseabsolute/catalauncher/util"
+)
+
+// Doctor checks that the host has everything the launcher needs to run the
+// game.
+type Doctor struct {
+	config *config.Config
+	local  *localbuilds.LocalBuilds
+	user   *curuser.User
+	asJSON bool
+	stdout io.Writer
+	stderr io.Writer
+}
+
+type Status string
+
+const (
+	Pass Status = "pass"
+	Warn Status = "warn"
+	Fail Status = "fail"
+)
+
+// Check is the result of a single check.
+type Check struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+	// Hint tells the user how to fix a warning or failure.
+	Hint string `json:"hint,omitempty"`
+}
+
+func New(rootDir string, asJSON bool) (*Doctor, error) {
+	c, err := config.New(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := curuser.New()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Doctor{
+		config: c,
+		local:  localbuilds.New(c),
+		user:   user,
+		asJSON: asJSON,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}, nil
+}
+
+// Diagnose runs all the checks and prints the results. It returns an error
+// if any check failed.
+func (d *Doctor) Diagnose() error {
+	checks, err := d.checks()
+	if err != nil {
+		return err
+	}
+
+	if d.asJSON {
+		out, err := json.MarshalIndent(checks, "", "  ")
+		if err != nil {
+			return fmt.Errorf("Could not encode the results as JSON: %s", err)
+		}
+		util.Say(d.stdout, string(out))
+	} else {
+		d.print(checks)
+	}
+
+	failed := 0
+	for _, c := range checks {
+		if c.Status == Fail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+
+	return nil
+}
+
+func (d *Doctor) checks() ([]Check, error) {
+	host, err := hostenv.Detect(d.user.Uid, d.user.HomeDir)
+	if err != nil {
+		return nil, err
+	}
+
+	checks := d.hostChecks(host)
+
+	if d.config.Native() {
+		c, err := d.nativeLibrariesCheck()
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, c)
+	} else {
+		checks = append(checks, d.runtimeChecks()...)
+	}
+
+	return checks, nil
+}
+
+func (d *Doctor) hostChecks(host *hostenv.Host) []Check {
+	checks := []Check{}
+
+	// If the game can use Wayland then X11 is optional.
+	wayland := host.WaylandSocket != "" && !d.config.ForceX11()
+	x11Missing := Fail
+	if wayland {
+		x11Missing = Warn
+	}
+
+	switch {
+	case host.WaylandSocket == "":
+		checks = append(checks, Check{
+			"Wayland", Pass, "This is not a Wayland session, so the game will use X11", "",
+		})
+	case d.config.ForceX11():
+		checks = append(checks, Check{
+			"Wayland", Pass,
+			fmt.Sprintf("Found the Wayland socket at %s, but the game will use X11 because force_x11 is set", host.WaylandSocket),
+			"",
+		})
+	default:
+		checks = append(checks, Check{
+			"Wayland", Pass, fmt.Sprintf("Found the Wayland socket at %s", host.WaylandSocket), "",
+		})
+	}
+
+	if host.Display != "" {
+		checks = append(checks, Check{"$DISPLAY", Pass, fmt.Sprintf("$DISPLAY is set to %s", host.Display), ""})
+	} else {
+		checks = append(checks, Check{
+			"$DISPLAY", x11Missing, "$DISPLAY is not set",
+			"Run the launcher from a terminal in your graphical desktop session",
+		})
+	}
+
+	checks = append(checks, pathCheck(
+		hostenv.X11SocketDir, host.X11Sockets, x11Missing,
+		"the game cannot connect to your X server",
+		"Make sure an X server (or XWayland) is running",
+	))
+	checks = append(checks, pathCheck(
+		hostenv.DRIDir, host.DRI, Warn,
+		"the game will not have GPU acceleration",
+		"Make sure your graphics drivers are installed and loaded",
+	))
+	checks = append(checks, pathCheck(
+		hostenv.MachineIDFile, host.MachineID, Warn,
+		"PulseAudio in the container may not be able to find your sound server",
+		`Run "sudo systemd-machine-id-setup" or "sudo dbus-uuidgen --ensure=/etc/machine-id"`,
+	))
+	// The game can only use PipeWire through its PulseAudio compatible
+	// server, so PipeWire on its own doesn't give the game sound.
+	pulseHint := "Make sure PulseAudio (or pipewire-pulse) is running for your user"
+	if host.PipeWireSocket != "" {
+		pulseHint = "PipeWire is running, so install pipewire-pulse and make sure it is running for your user"
+	}
+	checks = append(checks, pathCheck(
+		hostenv.PulseDirFor(d.user.Uid), host.PulseDir, Warn,
+		"the game will not have sound",
+		pulseHint,
+	))
+	checks = append(checks, pathCheck(
+		hostenv.HomePulseDirFor(d.user.HomeDir), host.HomePulseDir, Warn,
+		"older PulseAudio setups may not work",
+		"You can probably ignore this if sound works",
+	))
+	checks = append(checks, pathCheck(
+		hostenv.DBusDir, host.DBus, Warn,
+		"PulseAudio in the container may not be able to talk to D-Bus",
+		"Install D-Bus with your package manager",
+	))
+
+	return checks
+}
+
+func pathCheck(path, found string, missing Status, consequence, hint string) Check {
+	if found != "" {
+		return Check{path, Pass, fmt.Sprintf("%s exists", path), ""}
+	}
+	return Check{path, missing, fmt.Sprintf("%s does not exist, so %s", path, consequence), hint}
+}
+
+func (d *Doctor) runtimeChecks() []Check {
+	rt, err := container.New(d.config.ContainerRuntime())
+	if err != nil {
+		return []Check{{
+			"container runtime", Fail, err.Error(),
+			`Install Docker or Podman, or set runtime = "native" in your config file`,
+		}}
+	}
+
+	checks := []Check{}
+	path, err := exec.LookPath(rt.Executable())
+	if err != nil {
+		return append(checks, Check{
+			rt.Name(), Fail, fmt.Sprintf("Could not find %s in your $PATH", rt.Executable()),
+			fmt.Sprintf("Install %s or pick a different runtime with the setup subcommand", rt.Name()),
+		})
+	}
+	checks = append(checks, Check{rt.Name(), Pass, fmt.Sprintf("Found %s at %s", rt.Executable(), path), ""})
+
+	reachable, out := d.runtimeIsReachable(rt)
+	if reachable {
+		checks = append(checks, Check{
+			rt.Name() + " daemon", Pass, fmt.Sprintf("%s info ran successfully", rt.Executable()), "",
+		})
+	} else {
+		hint := "Make sure the Docker daemon is running, for example with \"sudo systemctl start docker\""
+		if rt.Name() == "podman" {
+			hint = "Check your Podman installation with \"podman info\""
+		}
+		checks = append(checks, Check{
+			rt.Name() + " daemon", Fail, fmt.Sprintf("%s info failed: %s", rt.Executable(), out), hint,
+		})
+	}
+
+	// Rootless Podman doesn't need any group membership.
+	if rt.Name() == "docker" {
+		checks = append(checks, d.dockerGroupCheck(reachable))
+	}
+
+	return checks
+}
+
+func (d *Doctor) runtimeIsReachable(rt container.Runtime) (bool, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, rt.Executable(), "info").CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			msg = err.Error()
+		}
+		// The full output of a failed info can be very long.
+		if lines := strings.SplitN(msg, "\n", 2); len(lines) > 1 {
+			msg = lines[0]
+		}
+		return false, msg
+	}
+
+	return true, ""
+}
+
+func (d *Doctor) dockerGroupCheck(reachable bool) Check {
+	if d.user.Uid == "0" {
+		return Check{"docker group", Pass, "You are root so you do not need to be in the docker group", ""}
+	}
+
+	inGroup, err := d.inGroup("docker")
+	if err != nil {
+		return Check{"docker group", Warn, err.Error(), ""}
+	}
+
+	switch {
+	case inGroup:
+		return Check{"docker group", Pass, "You are in the docker group", ""}
+	case reachable:
+		return Check{
+			"docker group", Pass,
+			"You are not in the docker group but the Docker daemon is reachable, so you may be using rootless Docker",
+			"",
+		}
+	default:
+		return Check{
+			"docker group", Fail, "You are not in the docker group",
+			`Run "sudo usermod -aG docker $USER" and then log out and back in`,
+		}
+	}
+}
+
+func (d *Doctor) inGroup(name string) (bool, error) {
+	group, err := user.LookupGroup(name)
+	if err != nil {
+		var unknown user.UnknownGroupError
+		if errors.As(err, &unknown) {
+			return false, nil
+		}
+		return false, fmt.Errorf("Could not look up the %s group: %s", name, err)
+	}
+
+	gids, err := d.user.GroupIds()
+	if err != nil {
+		return false, fmt.Errorf("Could not get your groups: %s", err)
+	}
+
+	for _, g := range gids {
+		if g == group.Gid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (d *Doctor) nativeLibrariesCheck() (Check, error) {
+	latest, err := d.local.Latest()
+	if err != nil {
+		return Check{}, err
+	}
+	if latest == 0 {
+		return Check{
+			"shared libraries", Warn, "No builds have been downloaded yet so the game's libraries cannot be checked",
+			"Run the launch subcommand to download a build",
+		}, nil
+	}
+
+	gameDir, err := d.config.GameDir(latest)
+	if err != nil {
+		return Check{}, err
+	}
+
+	binary := filepath.Join(gameDir, config.GameBinary)
+	missing, err := libcheck.Missing(binary)
+	if err != nil {
+		return Check{"shared libraries", Fail, err.Error(), ""}, nil
+	}
+	if len(missing) > 0 {
+		return Check{
+			"shared libraries", Fail,
+			fmt.Sprintf("Build #%d is missing these shared libraries: %s", latest, strings.Join(missing, ", ")),
+			"Install them with your package manager (on Debian and Ubuntu these are the libsdl2 packages)",
+		}, nil
+	}
+
+	return Check{
+		"shared libraries", Pass, fmt.Sprintf("All the shared libraries needed by build #%d are installed", latest), "",
+	}, nil
+}
+
+func (d *Doctor) print(checks []Check) {
+	for _, c := range checks {
+		util.Say(d.stdout, "[%s] %s: %s", strings.ToUpper(string(c.Status)), c.Name, c.Message)
+		if c.Hint != "" && c.Status != Pass {
+			util.Say(d.stdout, "       %s", c.Hint)
+		}
+	}
+}
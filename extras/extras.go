@@ -0,0 +1,451 @@
+package extras
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	git "github.com/gogs/git-module"
+	"github.com/houseabsolute/catalauncher/config"
+	"github.com/houseabsolute/catalauncher/util"
+	"github.com/otiai10/copy"
+)
+
+// Extras manages the git repos of extras like tilesets and soundpacks that
+// are copied into each build's game directory.
+type Extras struct {
+	config  *config.Config
+	sources []config.ExtrasSource
+	stdout  io.Writer
+	stderr  io.Writer
+}
+
+func New(c *config.Config) (*Extras, error) {
+	sources, err := c.ExtrasSources()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Extras{
+		config:  c,
+		sources: sources,
+		stdout:  os.Stdout,
+		stderr:  os.Stderr,
+	}, nil
+}
+
+// Prepare makes sure each source is cloned and checks out the commit that
+// should be copied into the game. That is the commit in the lockfile if
+// there is one for the source, then the source's ref if it has one, and
+// otherwise the latest commit on the source's branch. In offline mode
+// existing clones are not fetched and sources that have never been cloned
+// are skipped.
+func (e *Extras) Prepare(offline bool) error {
+	lock, err := e.readLock()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range e.sources {
+		locked := lock.find(s)
+
+		repo, err := e.open(s, offline)
+		if err != nil {
+			return err
+		}
+		if repo == nil {
+			continue
+		}
+
+		commit := ""
+		if locked != nil {
+			commit, err = e.lockedCommit(repo, s, locked, offline)
+		} else {
+			commit, err = e.latestCommit(repo, s, offline)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = e.checkout(repo, s, commit)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Update checks out the latest commit for each source, ignoring the
+// lockfile, and then writes the lockfile with the new commits.
+func (e *Extras) Update() error {
+	for _, s := range e.sources {
+		repo, err := e.open(s, false)
+		if err != nil {
+			return err
+		}
+
+		commit, err := e.latestCommit(repo, s, false)
+		if err != nil {
+			return err
+		}
+
+		err = e.checkout(repo, s, commit)
+		if err != nil {
+			return err
+		}
+	}
+
+	return e.Lock()
+}
+
+// Lock writes the lockfile with the commit that is currently checked out for
+// each source. Sources that haven't been cloned yet are cloned first.
+func (e *Extras) Lock() error {
+	lock := &Lock{Sources: []LockedSource{}}
+	for _, s := range e.sources {
+		repo, err := e.open(s, false)
+		if err != nil {
+			return err
+		}
+
+		commit, err := e.head(repo, s)
+		if err != nil {
+			return err
+		}
+
+		lock.Sources = append(lock.Sources, LockedSource{
+			URL:    s.URL,
+			Path:   s.Path,
+			Branch: s.Branch,
+			Ref:    s.Ref,
+			Commit: commit,
+		})
+		util.Say(e.stdout, "Locked the %s extras to %s", s.URL, commit)
+	}
+
+	return e.writeLock(lock)
+}
+
+// Commits returns the commit that is checked out for each source that has
+// been cloned.
+func (e *Extras) Commits() ([]LockedSource, error) {
+	commits := []LockedSource{}
+	for _, s := range e.sources {
+		err := e.migrateOldClone(s)
+		if err != nil {
+			return nil, err
+		}
+
+		dir := e.config.ExtrasSourceDir(s)
+		cloned, err := util.PathExists(filepath.Join(dir, ".git"))
+		if err != nil {
+			return nil, err
+		}
+		if !cloned {
+			continue
+		}
+
+		repo, err := git.Open(dir)
+		if err != nil {
+			return nil, fmt.Errorf("Could not open the git repo at %s: %s", dir, err)
+		}
+
+		commit, err := e.head(repo, s)
+		if err != nil {
+			return nil, err
+		}
+
+		commits = append(commits, LockedSource{
+			URL:    s.URL,
+			Path:   s.Path,
+			Branch: s.Branch,
+			Ref:    s.Ref,
+			Commit: commit,
+		})
+	}
+
+	return commits, nil
+}
+
+// open returns the repo for the source, cloning it first if needed. In
+// offline mode it returns nil if the source has never been cloned.
+func (e *Extras) open(s config.ExtrasSource, offline bool) (*git.Repository, error) {
+	err := e.migrateOldClone(s)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := e.config.ExtrasSourceDir(s)
+	cloned, err := util.PathExists(filepath.Join(dir, ".git"))
+	if err != nil {
+		return nil, err
+	}
+
+	if !cloned {
+		if offline {
+			util.Say(e.stdout, "Offline: skipping the %s extras because they have never been cloned", s.URL)
+			return nil, nil
+		}
+
+		err := os.MkdirAll(filepath.Dir(dir), 0755)
+		if err != nil {
+			return nil, fmt.Errorf("Could not make directory %s: %s", filepath.Dir(dir), err)
+		}
+
+		util.Say(e.stdout, "Cloning extras from %s to %s", s.URL, dir)
+		err = git.Clone(s.URL, dir, git.CloneOptions{Branch: s.Branch})
+		if err != nil {
+			return nil, fmt.Errorf("Could not clone %s: %s", s.URL, err)
+		}
+	}
+
+	repo, err := git.Open(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open the git repo at %s: %s", dir, err)
+	}
+
+	return repo, nil
+}
+
+// Before extras could come from more than one repo, the default extras repo
+// was cloned directly into the extras dir. If we find that clone we move it
+// to where the default source is cloned now, rather than cloning the repo
+// again inside the old clone.
+func (e *Extras) migrateOldClone(s config.ExtrasSource) error {
+	if s.URL != config.DefaultExtrasURL {
+		return nil
+	}
+
+	old := e.config.ExtrasDir()
+	for _, other := range e.sources {
+		// A source with a path of "." is cloned directly into the extras
+		// dir, so a clone there belongs to it.
+		if filepath.Clean(e.config.ExtrasSourceDir(other)) == filepath.Clean(old) {
+			return nil
+		}
+	}
+
+	exists, err := util.PathExists(filepath.Join(old, ".git"))
+	if err != nil || !exists {
+		return err
+	}
+
+	// The new location is inside the old clone, so we have to move the
+	// clone out of the way first.
+	tmp, err := ioutil.TempDir(e.config.RootDir(), ".extras-")
+	if err != nil {
+		return fmt.Errorf("Could not create a temporary directory in %s: %s", e.config.RootDir(), err)
+	}
+	// If the clone can't be moved back we leave it here rather than lose it,
+	// so this only removes the directory if it's empty.
+	defer os.Remove(tmp)
+
+	moved := filepath.Join(tmp, "clone")
+	err = os.Rename(old, moved)
+	if err != nil {
+		return fmt.Errorf("Could not move %s to %s: %s", old, moved, err)
+	}
+
+	dir := e.config.ExtrasSourceDir(s)
+	util.Say(e.stdout, "Moving the extras clone in %s to %s", old, dir)
+	err = os.MkdirAll(filepath.Dir(dir), 0755)
+	if err == nil {
+		err = os.Rename(moved, dir)
+	}
+	if err != nil {
+		// Put the clone back where we found it so that nothing is lost.
+		os.RemoveAll(old)
+		os.Rename(moved, old)
+		return fmt.Errorf("Could not move the extras clone in %s to %s: %s", old, dir, err)
+	}
+
+	return nil
+}
+
+// lockedCommit returns the commit in the lockfile for the source, fetching
+// it if it's not in the local clone.
+func (e *Extras) lockedCommit(repo *git.Repository, s config.ExtrasSource, locked *LockedSource, offline bool) (string, error) {
+	if e.hasCommit(repo, locked.Commit) {
+		return locked.Commit, nil
+	}
+
+	if offline {
+		util.Say(
+			e.stderr,
+			"Offline: commit %s from the lockfile is not in the local copy of the %s extras, using the local copy as is",
+			locked.Commit, s.URL,
+		)
+		return e.head(repo, s)
+	}
+
+	err := e.fetch(repo, s)
+	if err != nil {
+		return "", err
+	}
+
+	if !e.hasCommit(repo, locked.Commit) {
+		return "", fmt.Errorf(
+			"Commit %s from the lockfile does not exist in %s. Run \"catalauncher extras update\" to update the lockfile",
+			locked.Commit, s.URL,
+		)
+	}
+
+	return locked.Commit, nil
+}
+
+// latestCommit returns the commit for the source's ref, or the latest commit
+// on its branch if it doesn't have a ref.
+func (e *Extras) latestCommit(repo *git.Repository, s config.ExtrasSource, offline bool) (string, error) {
+	// A commit or tag won't change, so if we have it already we don't need
+	// to fetch.
+	if s.Ref != "" {
+		if commit, err := e.resolve(repo, s.Ref); err == nil {
+			return commit, nil
+		}
+	}
+
+	if offline {
+		util.Say(e.stdout, "Offline: not updating the %s extras, using the local copy", s.URL)
+		if s.Ref != "" {
+			util.Say(e.stderr, "Offline: %s is not in the local copy of the %s extras", s.Ref, s.URL)
+		}
+		return e.head(repo, s)
+	}
+
+	util.Say(e.stdout, "Updating extras from %s", s.URL)
+	err := e.fetch(repo, s)
+	if err != nil {
+		return "", err
+	}
+
+	rev := "origin/HEAD"
+	switch {
+	case s.Ref != "":
+		rev = s.Ref
+	case s.Branch != "":
+		rev = "origin/" + s.Branch
+	}
+
+	commit, err := e.resolve(repo, rev)
+	if err != nil {
+		return "", fmt.Errorf("Could not find %s in %s: %s", rev, s.URL, err)
+	}
+
+	return commit, nil
+}
+
+func (e *Extras) fetch(repo *git.Repository, s config.ExtrasSource) error {
+	_, err := git.NewCommand("fetch", "--tags", "origin").RunInDir(repo.Path())
+	if err != nil {
+		return fmt.Errorf("Could not fetch %s: %s", s.URL, err)
+	}
+	return nil
+}
+
+func (e *Extras) resolve(repo *git.Repository, rev string) (string, error) {
+	return repo.RevParse(rev + "^{commit}")
+}
+
+func (e *Extras) hasCommit(repo *git.Repository, commit string) bool {
+	_, err := e.resolve(repo, commit)
+	return err == nil
+}
+
+func (e *Extras) head(repo *git.Repository, s config.ExtrasSource) (string, error) {
+	commit, err := repo.RevParse("HEAD")
+	if err != nil {
+		return "", fmt.Errorf("Could not get the current commit of the %s extras: %s", s.URL, err)
+	}
+	return commit, nil
+}
+
+// checkout checks out the commit, leaving the clone with a detached HEAD.
+func (e *Extras) checkout(repo *git.Repository, s config.ExtrasSource, commit string) error {
+	head, err := e.head(repo, s)
+	if err != nil {
+		return err
+	}
+	if head == commit {
+		return nil
+	}
+
+	util.Say(e.stdout, "Checking out commit %s of the %s extras", commit, s.URL)
+	_, err = git.NewCommand("checkout", "--quiet", "--detach", commit).RunInDir(repo.Path())
+	if err != nil {
+		return fmt.Errorf("Could not check out commit %s of %s: %s", commit, s.URL, err)
+	}
+
+	return nil
+}
+
+// Install copies the extras from each source into the game directory
+// according to the source's layout. Sources are processed in order, so if
+// two sources provide the same entry the later one wins. Each conflict like
+// this is reported.
+func (e *Extras) Install(gameDir string) error {
+	// This maps each destination (relative to the game dir) to a description
+	// of where it was copied from.
+	copied := map[string]string{}
+
+	for _, s := range e.sources {
+		dir := e.config.ExtrasSourceDir(s)
+		exists, err := util.PathExists(dir)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+
+		for _, m := range s.Layout {
+			err := e.install(s, filepath.Join(dir, m.From), gameDir, m.To, copied)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (e *Extras) install(s config.ExtrasSource, from, gameDir, to string, copied map[string]string) error {
+	entries, err := ioutil.ReadDir(from)
+	if err != nil {
+		if os.IsNotExist(err) {
+			util.Say(e.stderr, "The %s extras do not have a %s directory", s.URL, from)
+			return nil
+		}
+		return fmt.Errorf("Could not read directory %s: %s", from, err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == ".git" {
+			continue
+		}
+
+		rel := filepath.Join(to, name)
+		dest := filepath.Join(gameDir, rel)
+		src := filepath.Join(from, name)
+
+		if other, ok := copied[rel]; ok {
+			util.Say(e.stderr, "Conflict: both %s and %s provide %s, using the one from %s", other, src, rel, src)
+			err := os.RemoveAll(dest)
+			if err != nil {
+				return fmt.Errorf("Could not remove %s: %s", dest, err)
+			}
+		}
+
+		util.Say(e.stdout, "Copying %s to %s", src, rel)
+		err := copy.Copy(src, dest)
+		if err != nil {
+			return fmt.Errorf("Could not copy %s to %s: %s", src, dest, err)
+		}
+		copied[rel] = src
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package extras
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/houseabsolute/catalauncher/config"
+	"github.com/houseabsolute/catalauncher/util"
+)
+
+// Lock is the contents of the extras lockfile, which records the exact
+// commit to use for each extras source.
+type Lock struct {
+	Sources []LockedSource `json:"sources"`
+}
+
+// LockedSource is the commit to use for one extras source. The source's
+// branch and ref are recorded so that we can tell when the source's config
+// has changed since it was locked.
+type LockedSource struct {
+	URL    string `json:"url"`
+	Path   string `json:"path"`
+	Branch string `json:"branch,omitempty"`
+	Ref    string `json:"ref,omitempty"`
+	Commit string `json:"commit"`
+}
+
+// find returns the entry for the source, or nil if there isn't one or the
+// source's config no longer matches the entry.
+func (l *Lock) find(s config.ExtrasSource) *LockedSource {
+	for i := range l.Sources {
+		ls := &l.Sources[i]
+		if ls.URL == s.URL && ls.Path == s.Path && ls.Branch == s.Branch && ls.Ref == s.Ref {
+			return ls
+		}
+	}
+	return nil
+}
+
+func (e *Extras) readLock() (*Lock, error) {
+	file := e.config.ExtrasLockFile()
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Lock{}, nil
+		}
+		return nil, fmt.Errorf("Could not read %s: %s", file, err)
+	}
+
+	lock := &Lock{}
+	err = json.Unmarshal(content, lock)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse %s as JSON: %s", file, err)
+	}
+
+	return lock, nil
+}
+
+func (e *Extras) writeLock(lock *Lock) error {
+	file := e.config.ExtrasLockFile()
+	content, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Could not encode the extras lockfile as JSON: %s", err)
+	}
+
+	return util.WriteFileAtomically(file, append(content, '\n'))
+}
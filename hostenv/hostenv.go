@@ -0,0 +1,231 @@
+package hostenv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/houseabsolute/catalauncher/util"
+)
+
+// These are the host resources that the game's container uses for video
+// and sound.
+const (
+	MachineIDFile = "/etc/machine-id"
+	DRIDir        = "/dev/dri"
+	X11SocketDir  = "/tmp/.X11-unix"
+	DBusDir       = "/var/lib/dbus"
+)
+
+// Host describes which of the resources the game needs are available on the
+// host. Each path is empty if the resource does not exist.
+type Host struct {
+	MachineID string
+	// PulseDir is the PulseAudio runtime directory, usually
+	// /run/user/<uid>/pulse. PipeWire's PulseAudio compatible server uses this
+	// directory too.
+	PulseDir string
+	// PipeWireSocket is PipeWire's own socket in the runtime directory. The
+	// game can't use this directly, but it tells us that PipeWire is running.
+	PipeWireSocket string
+	// HomePulseDir is the legacy ~/.pulse directory.
+	HomePulseDir string
+	DRI          string
+	X11Sockets   string
+	DBus         string
+	// WaylandSocket is the Wayland compositor's socket in the runtime
+	// directory. This is only looked for if $WAYLAND_DISPLAY is set.
+	WaylandSocket string
+	// Display is the value of $DISPLAY.
+	Display string
+
+	uid  string
+	home string
+}
+
+// Resource is a host resource that can be passed through to the game's
+// container.
+type Resource struct {
+	// Name is how the resource is referred to in the "[mounts]" section of
+	// the config file.
+	Name string
+	// Path is the resource's path on the host.
+	Path string
+	// Container is where the resource is mounted in the container. This is
+	// empty for devices.
+	Container string
+	// Device is true if the resource is passed to the container as a device
+	// rather than mounted.
+	Device bool
+	// Purpose says what the game loses without the resource.
+	Purpose string
+	// Found is true if the resource exists on the host.
+	Found bool
+}
+
+// The names of each resource, as used in the config file.
+const (
+	MachineIDResource  = "machine-id"
+	PulseResource      = "pulse"
+	HomePulseResource  = "home-pulse"
+	DBusResource       = "dbus"
+	DRIResource        = "dri"
+	X11SocketsResource = "x11"
+	WaylandResource    = "wayland"
+)
+
+// ResourceNames returns the names of all the resources the game's container
+// can use.
+func ResourceNames() []string {
+	return []string{
+		MachineIDResource,
+		PulseResource,
+		HomePulseResource,
+		DBusResource,
+		DRIResource,
+		X11SocketsResource,
+		WaylandResource,
+	}
+}
+
+// Resources returns every resource the game's container can use, whether or
+// not it exists on the host.
+func (h *Host) Resources() []Resource {
+	pulse := PulseDirFor(h.uid)
+	wayland := WaylandSocketFor(h.uid)
+	return []Resource{
+		{
+			Name:      MachineIDResource,
+			Path:      MachineIDFile,
+			Container: MachineIDFile,
+			Purpose:   "sound may not work",
+			Found:     h.MachineID != "",
+		},
+		{
+			Name:      PulseResource,
+			Path:      pulse,
+			Container: pulse,
+			Purpose:   "the game will run without sound",
+			Found:     h.PulseDir != "",
+		},
+		{
+			Name:      HomePulseResource,
+			Path:      HomePulseDirFor(h.home),
+			Container: "/.pulse",
+			Purpose:   "sound may not work with older PulseAudio setups",
+			Found:     h.HomePulseDir != "",
+		},
+		{
+			Name:      DBusResource,
+			Path:      DBusDir,
+			Container: DBusDir,
+			Purpose:   "sound may not work",
+			Found:     h.DBus != "",
+		},
+		{
+			Name:    DRIResource,
+			Path:    DRIDir,
+			Device:  true,
+			Purpose: "the game will run without GPU acceleration",
+			Found:   h.DRI != "",
+		},
+		{
+			Name:      X11SocketsResource,
+			Path:      X11SocketDir,
+			Container: X11SocketDir,
+			Purpose:   "the game will not be able to open a window",
+			Found:     h.X11Sockets != "",
+		},
+		{
+			Name:      WaylandResource,
+			Path:      wayland,
+			Container: wayland,
+			Purpose:   "the game will use X11 instead of Wayland",
+			Found:     h.WaylandSocket != "",
+		},
+	}
+}
+
+// RuntimeDirFor returns the runtime directory for the given uid. This is
+// $XDG_RUNTIME_DIR if it is set and /run/user/<uid> otherwise.
+func RuntimeDirFor(uid string) string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return dir
+	}
+	return fmt.Sprintf("/run/user/%s", uid)
+}
+
+// PulseDirFor returns the PulseAudio runtime directory for the given uid.
+func PulseDirFor(uid string) string {
+	return filepath.Join(RuntimeDirFor(uid), "pulse")
+}
+
+// PipeWireSocketFor returns the path of PipeWire's socket for the given uid.
+func PipeWireSocketFor(uid string) string {
+	return filepath.Join(RuntimeDirFor(uid), "pipewire-0")
+}
+
+// WaylandSocketFor returns the path of the Wayland compositor's socket for
+// the given uid. $WAYLAND_DISPLAY may be either a path or a name relative to
+// the runtime directory, and defaults to "wayland-0" like it does in
+// libwayland.
+func WaylandSocketFor(uid string) string {
+	display := os.Getenv("WAYLAND_DISPLAY")
+	if display == "" {
+		display = "wayland-0"
+	}
+	if filepath.IsAbs(display) {
+		return display
+	}
+	return filepath.Join(RuntimeDirFor(uid), display)
+}
+
+// HomePulseDirFor returns the legacy PulseAudio directory in the given home
+// directory.
+func HomePulseDirFor(home string) string {
+	return filepath.Join(home, ".pulse")
+}
+
+// Detect looks for the resources the game needs on the host.
+func Detect(uid, home string) (*Host, error) {
+	h := &Host{Display: os.Getenv("DISPLAY"), uid: uid, home: home}
+
+	// A Wayland socket may be left over from an earlier session, so we only
+	// use it if the current session is a Wayland session.
+	waylandSocket := ""
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		waylandSocket = WaylandSocketFor(uid)
+	}
+
+	for _, r := range []struct {
+		path  string
+		found *string
+	}{
+		{MachineIDFile, &h.MachineID},
+		{PulseDirFor(uid), &h.PulseDir},
+		{PipeWireSocketFor(uid), &h.PipeWireSocket},
+		{HomePulseDirFor(home), &h.HomePulseDir},
+		{DRIDir, &h.DRI},
+		{X11SocketDir, &h.X11Sockets},
+		{DBusDir, &h.DBus},
+		{waylandSocket, &h.WaylandSocket},
+	} {
+		if r.path == "" {
+			continue
+		}
+		exists, err := util.PathExists(r.path)
+		// If we can't stat it then we can't use it either, but that's not
+		// an error worth stopping for.
+		if os.IsPermission(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Could not check if %s exists: %s", r.path, err)
+		}
+		if exists {
+			*r.found = r.path
+		}
+	}
+
+	return h, nil
+}
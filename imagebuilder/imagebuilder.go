@@ -0,0 +1,95 @@
+package imagebuilder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/houseabsolute/catalauncher/config"
+	"github.com/houseabsolute/catalauncher/container"
+	"github.com/houseabsolute/catalauncher/docker"
+	"github.com/houseabsolute/catalauncher/util"
+)
+
+// ImageBuilder builds the image the game is run in from the Dockerfile
+// embedded in the launcher.
+type ImageBuilder struct {
+	config  *config.Config
+	runtime container.Runtime
+	tag     string
+	stdout  io.Writer
+	stderr  io.Writer
+}
+
+// DefaultTag is the tag given to locally built images by default. Images in
+// the "localhost" registry are never pulled by the launcher.
+const DefaultTag = "localhost/catalauncher-player:latest"
+
+func New(rootDir, tag string) (*ImageBuilder, error) {
+	c, err := config.New(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Native() {
+		return nil, errors.New("The game is run without a container, so there is no image to build")
+	}
+
+	rt, err := container.New(c.ContainerRuntime())
+	if err != nil {
+		return nil, err
+	}
+
+	if tag == "" {
+		tag = DefaultTag
+	}
+
+	return &ImageBuilder{
+		config:  c,
+		runtime: rt,
+		tag:     tag,
+		stdout:  os.Stdout,
+		stderr:  os.Stderr,
+	}, nil
+}
+
+// Build builds the image and tags it.
+func (b *ImageBuilder) Build() error {
+	dir, err := ioutil.TempDir("", "catalauncher-image-")
+	if err != nil {
+		return fmt.Errorf("Could not create a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "Dockerfile")
+	err = ioutil.WriteFile(file, docker.Dockerfile, 0644)
+	if err != nil {
+		return fmt.Errorf("Could not write %s: %s", file, err)
+	}
+
+	util.Say(b.stdout, "Building the %s image with %s", b.tag, b.runtime.Name())
+
+	args := b.runtime.BuildArgs(b.tag, dir)
+	cmd := exec.Command(b.runtime.Executable(), args...)
+	cmd.Stdout = b.stdout
+	cmd.Stderr = b.stderr
+	err = cmd.Run()
+	if err != nil {
+		return fmt.Errorf("Error running \"%s %s\": %s", b.runtime.Executable(), strings.Join(args, " "), err)
+	}
+
+	if b.tag != b.config.Image() {
+		util.Say(b.stdout, "")
+		util.Say(b.stdout, "To run the game in this image, add this to your config file:")
+		util.Say(b.stdout, "")
+		util.Say(b.stdout, "[image]")
+		util.Say(b.stdout, "name = %q", b.tag)
+	}
+
+	return nil
+}
@@ -8,47 +8,50 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"os/user"
 	"path/filepath"
-	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/cheggaaa/pb/v3"
-	git "github.com/gogs/git-module"
+	"github.com/houseabsolute/catalauncher/archiver"
 	"github.com/houseabsolute/catalauncher/config"
+	"github.com/houseabsolute/catalauncher/container"
 	"github.com/houseabsolute/catalauncher/curuser"
+	"github.com/houseabsolute/catalauncher/extras"
+	"github.com/houseabsolute/catalauncher/hostenv"
+	"github.com/houseabsolute/catalauncher/libcheck"
 	"github.com/houseabsolute/catalauncher/localbuilds"
+	"github.com/houseabsolute/catalauncher/mods"
+	"github.com/houseabsolute/catalauncher/snapshotter"
+	"github.com/houseabsolute/catalauncher/source"
 	"github.com/houseabsolute/catalauncher/util"
 	"github.com/otiai10/copy"
 	"github.com/skratchdot/open-golang/open"
 )
 
-type build struct {
-	uri         string
-	filename    string
-	version     string
-	buildNumber uint
-	date        time.Time
-}
-
 type Launcher struct {
 	config      *config.Config
 	local       *localbuilds.LocalBuilds
 	build       uint
+	offline     bool
 	user        *curuser.User
 	stdout      io.Writer
 	stderr      io.Writer
-	buildsURI   string
+	source      source.BuildSource
+	runtime     container.Runtime
+	log         io.Writer
 	currentUser *user.User
+	// mods are the ids of the mods that were copied into the build for this
+	// session.
+	mods []string
 }
 
-const defaultBuildsURI = "http://dev.narc.ro/cataclysm/jenkins-latest/Linux_x64/Tiles/"
-
-func New(rootDir string, build uint) (*Launcher, error) {
+func New(rootDir string, build uint, offline bool) (*Launcher, error) {
 	c, err := config.New(rootDir)
 	if err != nil {
 		return nil, err
@@ -59,240 +62,417 @@ func New(rootDir string, build uint) (*Launcher, error) {
 		return nil, err
 	}
 
+	src, err := source.New(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// In native mode there is no container runtime.
+	var rt container.Runtime
+	if !c.Native() {
+		rt, err = container.New(c.ContainerRuntime())
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &Launcher{
-		config:    c,
-		local:     localbuilds.New(c),
-		build:     build,
-		user:      user,
-		stdout:    os.Stdout,
-		stderr:    os.Stderr,
-		buildsURI: defaultBuildsURI,
+		config:  c,
+		local:   localbuilds.New(c),
+		build:   build,
+		offline: offline,
+		user:    user,
+		stdout:  os.Stdout,
+		stderr:  os.Stderr,
+		source:  src,
+		runtime: rt,
 	}, nil
 }
 
 func (l *Launcher) Launch() error {
-	wanted, err := l.determineWantedBuild()
+	closeLog, err := l.startSessionLog()
 	if err != nil {
 		return err
 	}
+	defer closeLog()
 
-	localLatest, err := l.local.Latest()
+	err = l.launch()
 	if err != nil {
-		return err
+		// The caller prints the error to the terminal, but we want it in the
+		// log too.
+		fmt.Fprintln(l.log, err)
 	}
 
-	exists, err := l.local.HasBuild(wanted.buildNumber)
+	return err
+}
+
+func (l *Launcher) launch() error {
+	err := l.removeStaleStagingDirs()
 	if err != nil {
 		return err
 	}
 
-	if !exists {
-		err := l.downloadBuild(wanted)
-		if err != nil {
-			return err
+	var num uint
+	if l.offline {
+		util.Say(l.stdout, "Running in offline mode")
+		num, err = l.determineLocalBuild()
+	} else {
+		num, err = l.installWantedBuild()
+		var netErr *source.NetworkError
+		if errors.As(err, &netErr) {
+			util.Say(l.stderr, "%s", err)
+			util.Say(l.stderr, "The network seems to be unreachable, so falling back to offline mode")
+			l.offline = true
+			num, err = l.determineLocalBuild()
 		}
-		if localLatest != 0 {
-			err = l.copyTemplates(localLatest, wanted.buildNumber)
-			if err != nil {
-				return err
-			}
+	}
+	if err != nil {
+		return err
+	}
 
-			err = l.copyGameConfig(localLatest, wanted.buildNumber)
-			if err != nil {
-				return err
-			}
-		}
+	err = l.makeGameConfigDir(num)
+	if err != nil {
+		return err
+	}
+
+	err = l.updateExtras(num)
+	if err != nil {
+		return err
 	}
 
-	err = l.makeGameConfigDir(wanted)
+	var image string
+	if l.config.Native() {
+		err = l.checkNativeLibraries(num)
+	} else {
+		image, err = l.prepareImage(num)
+	}
 	if err != nil {
 		return err
 	}
 
-	err = l.updateExtras(wanted)
+	err = l.backupSaves()
 	if err != nil {
 		return err
 	}
 
-	err = l.pullDockerImage()
+	err = l.local.MarkLaunched(num)
 	if err != nil {
 		return err
 	}
 
-	return l.launchGame(wanted)
+	err = l.launchGame(num, image)
+	l.recordModSession(num, err)
+
+	return err
 }
 
-func (l *Launcher) determineWantedBuild() (build, error) {
-	if l.build == 0 {
-		return l.latestBuild()
+// recordModSession records whether the game crashed with the mods we copied
+// into the build. Sessions where the game was stopped by the launcher or
+// couldn't be started at all don't tell us anything about the mods. Failing
+// to record the session only gets a warning, since the game itself ran fine.
+func (l *Launcher) recordModSession(num uint, err error) {
+	code := 0
+	if err != nil {
+		var exitErr *ExitError
+		if !errors.As(err, &exitErr) || exitErr.Stopped {
+			return
+		}
+		// These are the statuses the container runtimes use when they
+		// couldn't run the game.
+		if !l.config.Native() && exitErr.Code >= 125 && exitErr.Code <= 127 {
+			return
+		}
+		code = exitErr.Code
 	}
 
-	builds, err := l.parseBuilds()
+	err = mods.New(l.config).RecordSession(num, l.mods, code)
 	if err != nil {
-		return build{}, err
+		util.Say(l.stderr, "Could not record how the session with mods ended: %s", err)
 	}
+}
 
-	var wanted build
-	for _, b := range builds {
-		if b.buildNumber == l.build {
-			wanted = b
-			break
+// backupSaves snapshots all the worlds so that a build that corrupts a save
+// doesn't cost anyone their character.
+func (l *Launcher) backupSaves() error {
+	keep := l.config.SaveBackups()
+	if keep == 0 {
+		return nil
+	}
+
+	s, err := snapshotter.New(l.config.RootDir())
+	if err != nil {
+		return err
+	}
+
+	util.Say(l.stdout, "Backing up your saves")
+	return s.Backup(keep)
+}
+
+// installWantedBuild finds the build we want in the build source and
+// downloads it if we don't have it yet. It returns the build's number.
+func (l *Launcher) installWantedBuild() (uint, error) {
+	wanted, err := l.determineWantedBuild()
+	if err != nil {
+		return 0, err
+	}
+
+	localLatest, err := l.local.Latest()
+	if err != nil {
+		return 0, err
+	}
+
+	exists, err := l.local.HasBuild(wanted.Number)
+	if err != nil {
+		return 0, err
+	}
+
+	if !exists {
+		err := l.downloadBuild(wanted)
+		if err != nil {
+			return 0, err
+		}
+		if localLatest != 0 {
+			err = l.copyTemplates(localLatest, wanted.Number)
+			if err != nil {
+				return 0, err
+			}
+
+			err = l.copyGameConfig(localLatest, wanted.Number)
+			if err != nil {
+				return 0, err
+			}
 		}
 	}
-	if wanted.uri == "" {
-		return build{}, fmt.Errorf(
-			"Could not find the build you requested, #%d, in the list of available builds", l.build)
+
+	return wanted.Number, nil
+}
+
+// determineLocalBuild picks a build without looking at the build source. If
+// a build was asked for it must already be installed, otherwise we use the
+// newest local build.
+func (l *Launcher) determineLocalBuild() (uint, error) {
+	util.Say(l.stdout, "Offline: not checking %s for new builds", l.source.Name())
+
+	if l.build != 0 {
+		exists, err := l.local.HasBuild(l.build)
+		if err != nil {
+			return 0, err
+		}
+		if !exists {
+			return 0, fmt.Errorf("You asked for build #%d but it has not been downloaded, so it cannot be launched offline", l.build)
+		}
+		return l.build, nil
+	}
+
+	latest, err := l.local.Latest()
+	if err != nil {
+		return 0, err
+	}
+	if latest == 0 {
+		return 0, errors.New("No builds have been downloaded yet, so there is nothing to launch offline")
+	}
+
+	util.Say(l.stdout, "Launching the latest local build, #%d", latest)
+	return latest, nil
+}
+
+func (l *Launcher) determineWantedBuild() (source.Build, error) {
+	if l.build == 0 {
+		return l.latestBuild()
 	}
 
-	return wanted, nil
+	util.Say(l.stdout, "Looking for build #%d in %s", l.build, l.source.Name())
+	return l.source.Build(l.build)
 }
 
-func (l *Launcher) latestBuild() (build, error) {
+func (l *Launcher) latestBuild() (source.Build, error) {
 	builds, err := l.parseBuilds()
 	if err != nil {
-		return build{}, err
+		return source.Build{}, err
 	}
 
 	if len(builds) == 0 {
-		return build{}, errors.New("Could not find any builds!")
+		return source.Build{}, errors.New("Could not find any builds!")
 	}
 
 	util.Say(l.stdout, "Found %d builds", len(builds))
 
 	localLatest, err := l.local.Latest()
 	if err != nil {
-		return build{}, err
+		return source.Build{}, err
 	}
 
 	if localLatest == 0 {
 		util.Say(l.stdout, "No builds have been downloaded yet")
-	} else if localLatest != builds[0].buildNumber {
-		util.Say(l.stdout, "Latest local build is #%d", localLatest)
+	} else if localLatest != builds[0].Number {
+		m, err := l.local.Get(localLatest)
+		if err != nil {
+			return source.Build{}, err
+		}
+		if m.ReleaseDate.IsZero() {
+			util.Say(l.stdout, "Latest local build is #%d", localLatest)
+		} else {
+			util.Say(
+				l.stdout,
+				"Latest local build is #%d, released %s",
+				localLatest, m.ReleaseDate.Format("2006-01-02 15:04"),
+			)
+		}
 		util.Say(
 			l.stdout,
 			"The latest build is build #%d, released %s",
-			builds[0].buildNumber, builds[0].date.Format("2006-01-02 15:04"),
+			builds[0].Number, builds[0].Date.Format("2006-01-02 15:04"),
 		)
 	} else {
 		util.Say(
 			l.stdout,
 			"You have the latest build, #%d, released %s",
-			builds[0].buildNumber, builds[0].date.Format("2006-01-02 15:04"),
+			builds[0].Number, builds[0].Date.Format("2006-01-02 15:04"),
 		)
 	}
 
 	return builds[0], nil
 }
 
-var fileRE = regexp.MustCompile(`^cataclysmdda-([0-9].[A-Z]-Linux_x64-Tiles-(\d+))\.tar\.gz$`)
+func (l *Launcher) parseBuilds() ([]source.Build, error) {
+	util.Say(l.stdout, "Getting list of builds from %s", l.source.Name())
+	return l.source.Builds()
+}
 
-func (l *Launcher) parseBuilds() ([]build, error) {
-	util.Say(l.stdout, "Getting list of builds from %s", l.buildsURI)
-	res, err := http.Get(l.buildsURI)
+// If a previous install was interrupted it may have left a staging dir
+// behind.
+func (l *Launcher) removeStaleStagingDirs() error {
+	stale, err := filepath.Glob(filepath.Join(l.config.BuildsDir(), config.StagingPrefix+"*"))
 	if err != nil {
-		return []build{}, fmt.Errorf("Could not fetch build list from %s: %s", l.buildsURI, err)
+		return err
 	}
-	defer res.Body.Close()
-	if res.StatusCode != 200 {
-		return []build{}, fmt.Errorf(
-			"Did not get a 200 status when fetching %s, got a %d (%s) instead",
-			l.buildsURI, res.StatusCode, res.Status,
-		)
+
+	for _, s := range stale {
+		util.Say(l.stdout, "Removing the incomplete build install at %s", s)
+		err := os.RemoveAll(s)
+		if err != nil {
+			return fmt.Errorf("Could not remove %s: %s", s, err)
+		}
 	}
 
-	doc, err := goquery.NewDocumentFromReader(res.Body)
-	if err != nil {
-		return []build{}, fmt.Errorf("Error parsing HTML from %s: %s", l.buildsURI, err)
+	return nil
+}
+
+func (l *Launcher) downloadBuild(b source.Build) error {
+	if changes := l.source.Metadata(b).ChangesURI; changes != "" {
+		util.Say(l.stdout, "Opening the changes listing in your browser")
+		open.Start(changes)
 	}
 
-	buildDates, err := l.parseBuildDates(doc)
+	err := l.mkdir(l.config.CacheDir())
 	if err != nil {
-		return []build{}, err
+		return err
 	}
 
-	builds := []build{}
-	var eachErr error
-	doc.Find("a").Each(func(_ int, sel *goquery.Selection) {
-		if eachErr != nil {
-			return
-		}
-
-		href, _ := sel.Attr("href")
-		m := fileRE.FindStringSubmatch(href)
-		if len(m) < 2 {
-			return
-		}
-
-		num, err := strconv.Atoi(m[2])
-		if err != nil {
-			eachErr = fmt.Errorf("Could not convert %s to an integer: %s", m[2], err)
-		}
-
-		builds = append(
-			builds,
-			build{
-				uri:         l.buildsURI + href,
-				filename:    href,
-				version:     m[1],
-				buildNumber: uint(num),
-				date:        buildDates[href],
-			},
-		)
-	})
-	if eachErr != nil {
-		return []build{}, eachErr
+	file := filepath.Join(l.config.CacheDir(), b.Filename)
+	cached, err := util.PathExists(file)
+	if err != nil {
+		return err
 	}
 
-	// Using After gives us a reverse sorting from most to least recent.
-	sort.SliceStable(builds, func(i, j int) bool { return builds[i].date.After(builds[j].date) })
-	return builds, nil
-}
-
-var buildDatesRE = regexp.MustCompile(`(cataclysmdda-\S+\.tar\.gz)\s+(2\d\d\d-\d\d-\d\d \d\d:\d\d)`)
-
-func (l *Launcher) parseBuildDates(doc *goquery.Document) (map[string]time.Time, error) {
-	dates := map[string]time.Time{}
-	m := buildDatesRE.FindAllStringSubmatch(doc.Find("body").First().Text(), -1)
-	for _, pair := range m {
-		d, err := time.Parse("2006-01-02 15:04", pair[2])
+	if cached {
+		util.Say(l.stdout, "Using the cached download of build #%d at %s", b.Number, file)
+	} else {
+		err = l.fetchBuild(l.source.DownloadURL(b), file, b)
 		if err != nil {
-			return dates, fmt.Errorf("Could not parse date for the file %s from text (%s)", pair[1], pair[2])
+			return err
 		}
-		dates[pair[1]] = d
 	}
-	return dates, nil
-}
 
-const changesURI = "http://gorgon.narc.ro:8080/job/Cataclysm-Matrix/changes"
+	sum, err := util.FileSHA256(file)
+	if err != nil {
+		return err
+	}
 
-func (l *Launcher) downloadBuild(b build) error {
-	util.Say(l.stdout, "Downloading build #%d from %s", b.buildNumber, b.uri)
-	util.Say(l.stdout, "Opening the changes listing in your browser")
-	open.Start(changesURI)
+	sum, err = l.verifyChecksum(b, sum)
+	if err != nil {
+		// There's no point in keeping a bad archive around, and if we did
+		// we'd just try to use it again next time.
+		os.Remove(file)
+		return err
+	}
 
-	dir, err := ioutil.TempDir("", "catalauncher-")
+	// We use the mtime to decide which archives to remove from the cache
+	// first.
+	now := time.Now()
+	err = os.Chtimes(file, now, now)
 	if err != nil {
-		return fmt.Errorf("Could not create a temporary directory: %s", err)
+		return fmt.Errorf("Could not update the modification time of %s: %s", file, err)
 	}
 
-	file := filepath.Join(dir, b.filename)
-	out, err := os.Create(file)
+	err = l.installBuild(file, sum, b)
 	if err != nil {
-		return fmt.Errorf("Could not create file at %s: %s", file, err)
+		return err
+	}
+
+	return l.pruneCache(file)
+}
+
+// fetchBuild downloads the build to a ".part" file next to file and renames
+// it to file once it's complete. If a ".part" file already exists we ask the
+// server for just the bytes we don't have yet.
+func (l *Launcher) fetchBuild(uri, file string, b source.Build) error {
+	part := file + ".part"
+	var offset int64
+	st, err := os.Stat(part)
+	if err == nil {
+		offset = st.Size()
+	} else if !os.IsNotExist(err) {
+		return err
 	}
-	defer out.Close()
 
-	req, _ := http.NewRequest("GET", b.uri, nil)
+	req, _ := http.NewRequest("GET", uri, nil)
 	req.Header.Set("Connection", "Keep-Alive")
 	req.Header.Set("Accept-Language", "en-US")
 	req.Header.Set("User-Agent", "Mozilla/5.0")
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := source.Client.Do(req)
 	if err != nil {
-		return fmt.Errorf("Could not get %s: %s", b.uri, err)
+		return &source.NetworkError{URI: uri, Err: err}
+	}
+	body := source.IdleTimeout(uri, resp.Body, source.ReadTimeout)
+	defer body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		util.Say(l.stdout, "Downloading build #%d from %s", b.Number, uri)
+		offset = 0
+	case http.StatusPartialContent:
+		util.Say(l.stdout, "Resuming the download of build #%d from %s at byte %d", b.Number, uri, offset)
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial file is either complete or bogus. Either way starting
+		// over is the simplest thing to do.
+		err := os.Remove(part)
+		if err != nil {
+			return fmt.Errorf("Could not remove %s: %s", part, err)
+		}
+		return l.fetchBuild(uri, file, b)
+	default:
+		return fmt.Errorf(
+			"Did not get a 200 status when fetching %s, got a %d (%s) instead",
+			uri, resp.StatusCode, resp.Status,
+		)
 	}
-	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	if offset > 0 {
+		flags |= os.O_APPEND
+	} else {
+		flags |= os.O_TRUNC
+	}
+	out, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("Could not create file at %s: %s", part, err)
+	}
+	defer out.Close()
 
 	cl := resp.Header.Get("Content-Length")
 	len := 0
@@ -303,38 +483,177 @@ func (l *Launcher) downloadBuild(b build) error {
 		}
 	}
 
-	bar := pb.New(len)
+	bar := pb.New64(offset + int64(len))
+	bar.SetCurrent(offset)
 	bar.Start()
-	rd := bar.NewProxyReader(resp.Body)
+	rd := bar.NewProxyReader(body)
 
-	_, err = io.Copy(out, rd)
+	written, err := io.Copy(out, rd)
+	bar.Finish()
 	if err != nil {
-		return fmt.Errorf("Could not save %s to %s: %s", b.uri, file, err)
+		return fmt.Errorf(
+			"Could not save %s to %s: %w (run the launcher again to resume the download)", uri, part, err)
 	}
 
-	return l.untarBuild(file, b)
+	if cl != "" && written != int64(len) {
+		return fmt.Errorf(
+			"The download of %s is incomplete, expected %d bytes but got %d (run the launcher again to resume the download)",
+			uri, len, written,
+		)
+	}
+
+	err = out.Close()
+	if err != nil {
+		return fmt.Errorf("Could not write to %s: %s", part, err)
+	}
+
+	err = os.Rename(part, file)
+	if err != nil {
+		return fmt.Errorf("Could not move %s to %s: %s", part, file, err)
+	}
+
+	return nil
 }
 
-func (l *Launcher) untarBuild(file string, b build) error {
-	target := filepath.Join(l.config.BuildsDir(), fmt.Sprintf("%d", b.buildNumber))
-	err := l.mkdir(target)
+// pruneCache removes the least recently used archives from the cache until
+// it's under the configured size. The archive we just used is never removed.
+func (l *Launcher) pruneCache(keep string) error {
+	max := l.config.CacheMaxBytes()
+	if max == 0 {
+		return nil
+	}
+
+	entries, err := ioutil.ReadDir(l.config.CacheDir())
+	if err != nil {
+		return fmt.Errorf("Could not read directory at %s: %s", l.config.CacheDir(), err)
+	}
+
+	var total int64
+	for _, e := range entries {
+		if e.Mode().IsRegular() {
+			total += e.Size()
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ModTime().Before(entries[j].ModTime()) })
+	for _, e := range entries {
+		if total <= max {
+			break
+		}
+
+		file := filepath.Join(l.config.CacheDir(), e.Name())
+		if !e.Mode().IsRegular() || file == keep {
+			continue
+		}
+
+		util.Say(l.stdout, "Removing %s from the download cache", e.Name())
+		err := os.Remove(file)
+		if err != nil {
+			return fmt.Errorf("Could not remove %s: %s", file, err)
+		}
+		total -= e.Size()
+	}
+
+	return nil
+}
+
+func (l *Launcher) verifyChecksum(b source.Build, got string) (string, error) {
+	expect, err := l.source.SHA256(b)
+	if err != nil {
+		return "", err
+	}
+
+	if expect == "" {
+		util.Say(l.stdout, "The build source does not publish a checksum for %s", b.Filename)
+		return got, nil
+	}
+
+	if got != expect {
+		return "", fmt.Errorf(
+			"The SHA-256 checksum for %s does not match, expected %s but got %s", b.Filename, expect, got)
+	}
+	util.Say(l.stdout, "Verified the SHA-256 checksum for %s", b.Filename)
+
+	return got, nil
+}
+
+// installBuild extracts the build into a staging directory and only moves it
+// to its real location once we know that it contains a usable game. If
+// anything goes wrong the staging directory is removed, so we never end up
+// with a half-installed build that looks like a real one.
+func (l *Launcher) installBuild(file, sum string, b source.Build) (err error) {
+	staging := l.config.StagingDir(b.Number)
+	err = os.RemoveAll(staging)
+	if err != nil {
+		return fmt.Errorf("Could not remove %s: %s", staging, err)
+	}
+
+	err = l.mkdir(staging)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(staging)
+		}
+	}()
+
+	util.Say(l.stdout, "Extracting %s to %s", filepath.Base(file), staging)
+	err = archiver.Extract(file, staging)
+	if err != nil {
+		return fmt.Errorf("Could not extract %s to %s: %s", file, staging, err)
+	}
+
+	_, err = config.FindGameDir(staging)
+	if err != nil {
+		return fmt.Errorf("The archive for build #%d does not contain a usable game: %s", b.Number, err)
+	}
+
+	files, err := localbuilds.HashFiles(staging)
+	if err != nil {
+		return err
+	}
+	meta := l.source.Metadata(b)
+	err = localbuilds.WriteManifest(staging, &localbuilds.Manifest{
+		Number:        b.Number,
+		Version:       meta.Version,
+		Variant:       b.Variant,
+		ReleaseDate:   meta.Date,
+		Source:        meta.Source,
+		SourceURL:     l.source.DownloadURL(b),
+		ChangesURL:    meta.ChangesURI,
+		InstalledAt:   time.Now(),
+		Archive:       b.Filename,
+		ArchiveSHA256: sum,
+		Files:         files,
+	})
 	if err != nil {
 		return err
 	}
 
-	cmd := exec.Command("tar", "xzf", file, "-C", target)
-	out, err := cmd.CombinedOutput()
+	target := l.config.BuildDir(b.Number)
+	util.Say(l.stdout, "Installing build #%d to %s", b.Number, target)
+	err = os.Rename(staging, target)
 	if err != nil {
-		return fmt.Errorf(`Could not run "tar xvzf %s -C %s": %s\n%s`, file, target, err, out)
+		return fmt.Errorf("Could not move %s to %s: %s", staging, target, err)
 	}
 
 	return nil
 }
 
 func (l *Launcher) copyTemplates(from, to uint) error {
+	fromDir, err := l.config.GameDir(from)
+	if err != nil {
+		return err
+	}
+	toDir, err := l.config.GameDir(to)
+	if err != nil {
+		return err
+	}
+
 	return l.rcopy(
-		filepath.Join(l.config.GameDir(from), "templates"),
-		filepath.Join(l.config.GameDir(to), "templates"),
+		filepath.Join(fromDir, "templates"),
+		filepath.Join(toDir, "templates"),
 		"template",
 	)
 }
@@ -342,8 +661,17 @@ func (l *Launcher) copyTemplates(from, to uint) error {
 // Some config files end up in the game dir even when you pass
 // --configdir. Why?
 func (l *Launcher) copyGameConfig(from, to uint) error {
-	fromDir := filepath.Join(l.config.GameDir(from), "config")
-	_, err := os.Stat(fromDir)
+	fromGameDir, err := l.config.GameDir(from)
+	if err != nil {
+		return err
+	}
+	toGameDir, err := l.config.GameDir(to)
+	if err != nil {
+		return err
+	}
+
+	fromDir := filepath.Join(fromGameDir, "config")
+	_, err = os.Stat(fromDir)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil
@@ -353,73 +681,57 @@ func (l *Launcher) copyGameConfig(from, to uint) error {
 
 	return l.rcopy(
 		fromDir,
-		filepath.Join(l.config.GameDir(to), "config"),
+		filepath.Join(toGameDir, "config"),
 		"config",
 	)
 }
 
-func (l *Launcher) makeGameConfigDir(b build) error {
-	return os.MkdirAll(filepath.Join(l.config.GameDir(b.buildNumber), "config"), 0755)
+func (l *Launcher) makeGameConfigDir(num uint) error {
+	gameDir, err := l.config.GameDir(num)
+	if err != nil {
+		return err
+	}
+
+	return os.MkdirAll(filepath.Join(gameDir, "config"), 0755)
 }
 
-const extrasGitRepo = "https://github.com/houseabsolute/cataclysm-extras-collection.git"
+func (l *Launcher) updateExtras(num uint) error {
+	gameDir, err := l.config.GameDir(num)
+	if err != nil {
+		return err
+	}
 
-func (l *Launcher) updateExtras(b build) error {
-	err := l.mkdir(l.config.ExtrasDir())
+	e, err := extras.New(l.config)
 	if err != nil {
 		return err
 	}
 
-	_, err = os.Stat(filepath.Join(l.config.ExtrasDir(), ".git"))
+	err = e.Prepare(l.offline)
 	if err != nil {
-		if !os.IsNotExist(err) {
-			return err
-		}
-		util.Say(l.stdout, "Cloning extras from %s", extrasGitRepo)
-		err = git.Clone(extrasGitRepo, l.config.ExtrasDir(), git.CloneOptions{})
-		if err != nil {
-			return err
-		}
+		return err
 	}
 
-	repo, err := git.Open(l.config.ExtrasDir())
+	err = e.Install(gameDir)
 	if err != nil {
 		return err
 	}
 
-	util.Say(l.stdout, "Updating extras git repo")
-	err = repo.Pull(git.PullOptions{Remote: "origin"})
+	l.mods, err = mods.New(l.config).Install(num)
 	if err != nil {
 		return err
 	}
 
-	things := []struct {
-		from      string
-		to        string
-		what      string
-		underData bool
-	}{
-		{"gfx", "gfx", "tileset", false},
-//		{"mods", "mods", "mod", true},
-		{"soundpacks", "sound", "soundpack", true},
+	sources, err := e.Commits()
+	if err != nil {
+		return err
 	}
-	for _, t := range things {
-		toElt := []string{l.config.GameDir(b.buildNumber)}
-		if t.underData {
-			toElt = append(toElt, "data")
-		}
-		toElt = append(toElt, t.to)
-		err = l.rcopy(
-			filepath.Join(l.config.ExtrasDir(), t.from),
-			filepath.Join(toElt...),
-			t.what,
-		)
-		if err != nil {
-			return err
-		}
+
+	commits := []localbuilds.ExtrasCommit{}
+	for _, s := range sources {
+		commits = append(commits, localbuilds.ExtrasCommit{URL: s.URL, Commit: s.Commit})
 	}
 
-	return nil
+	return l.local.SetExtras(num, commits)
 }
 
 func (l *Launcher) rcopy(from, to, what string) error {
@@ -447,72 +759,526 @@ func (l *Launcher) rcopy(from, to, what string) error {
 	return nil
 }
 
-func (l *Launcher) pullDockerImage() error {
-	util.Say(l.stdout, "Pulling the latest houseabsolute/cataplayer-launcher image")
-	return l.runCommand("docker", []string{"pull", "houseabsolute/catalauncher-player"})
+// prepareImage returns the image to run the game in, pulling it first if
+// needed.
+func (l *Launcher) prepareImage(num uint) (string, error) {
+	if l.config.UsePinnedImage() {
+		pinned, err := l.pinnedImage(num)
+		if err != nil {
+			return "", err
+		}
+		if pinned != "" {
+			util.Say(l.stdout, "Using image %s, which build #%d last exited cleanly with", pinned, num)
+			return pinned, nil
+		}
+	}
+
+	image := l.config.Image()
+	switch {
+	case l.offline:
+		util.Say(l.stdout, "Offline: skipping the %s image pull and using the local image", l.runtime.Name())
+	case container.IsLocal(image):
+		util.Say(l.stdout, "Not pulling the %s image because it was built locally", image)
+	default:
+		util.Say(l.stdout, "Pulling the %s image", image)
+		err := l.runCommand(l.runtime.Executable(), l.runtime.PullArgs(image))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return image, nil
 }
 
-func (l *Launcher) launchGame(b build) error {
+// pinnedImage returns the image the build last exited cleanly with, or an
+// empty string if there isn't one or it can no longer be found. If a pinned
+// repo digest has been removed locally we try to pull it again.
+func (l *Launcher) pinnedImage(num uint) (string, error) {
+	m, err := l.local.Get(num)
+	if err != nil {
+		return "", err
+	}
+	if m.Image == "" {
+		return "", nil
+	}
+
+	_, err = container.ImageID(l.runtime, m.Image)
+	if err == nil {
+		return m.Image, nil
+	}
+
+	if container.IsRepoDigest(m.Image) && !l.offline {
+		util.Say(l.stdout, "Pulling the %s image pinned for build #%d", m.Image, num)
+		err = l.runCommand(l.runtime.Executable(), l.runtime.PullArgs(m.Image))
+		if err == nil {
+			return m.Image, nil
+		}
+	}
+
+	util.Say(l.stderr, "The %s image pinned for build #%d no longer exists, so using %s instead", m.Image, num, l.config.Image())
+	return "", nil
+}
+
+// pinImage records the image in the build's manifest so that the build can be
+// run with the same image later.
+func (l *Launcher) pinImage(num uint, ref string) error {
+	m, err := l.local.Get(num)
+	if err != nil {
+		return err
+	}
+	if m.Image == ref {
+		return nil
+	}
+
+	util.Say(l.stdout, "Pinning image %s to build #%d", ref, num)
+	return l.local.PinImage(num, ref)
+}
+
+// imageRef returns what to pin a build to for the given image. For pulled
+// images this is the repo digest, which can be pulled again if the image is
+// removed. Locally built images have no repo digest, so we fall back to their
+// id.
+func (l *Launcher) imageRef(image string) (string, error) {
+	if !container.IsLocal(image) && !container.IsImageID(image) {
+		digest, err := container.RepoDigest(l.runtime, image)
+		if err != nil {
+			return "", err
+		}
+		if digest != "" {
+			return digest, nil
+		}
+	}
+
+	return container.ImageID(l.runtime, image)
+}
+
+func (l *Launcher) launchGame(num uint, image string) error {
+	gameDir, err := l.config.GameDir(num)
+	if err != nil {
+		return err
+	}
+
 	dataDir := l.config.GameDataDir()
-	err := l.mkdir(dataDir)
+	err = l.mkdir(dataDir)
 	if err != nil {
 		return err
 	}
 
-	runPulse := fmt.Sprintf("/run/user/%s/pulse", l.user.Uid)
+	if l.config.Native() {
+		return l.launchNativeGame(gameDir, dataDir)
+	}
 
-	args := []string{
-		"run",
-		// We don't want the container sticking around once the game exits.
-		"--rm",
-		// We want to make sure save files and such are owned by the current
-		// user, not root.
-		"--user", fmt.Sprintf("%s:%s", l.user.Uid, l.user.Gid),
-		// Needed for sound w/ Pulseaudio
-		"-v", "/etc/machine-id:/etc/machine-id",
-		"-v", runPulse + ":" + runPulse,
-		"-v", "/var/lib/dbus:/var/lib/dbus",
-		"-v", fmt.Sprintf("%s/.pulse:/.pulse", l.user.HomeDir),
-		// Needed for graphics
-		"-e", "DISPLAY",
-		"--device", "/dev/dri",
-		"-v", "/tmp/.X11-unix:/tmp/.X11-unix",
-		//
-		"-v", dataDir + ":/data",
-		"-v", l.config.GameDir(b.buildNumber) + ":/game",
+	name := container.GameContainerName(l.user.Uid)
+	err = l.removeStaleContainer(name)
+	if err != nil {
+		return err
+	}
+
+	env, devices, mounts, err := l.hostResources()
+	if err != nil {
+		return err
+	}
+	mounts = append(
+		mounts,
+		container.Mount{Host: dataDir, Container: "/data"},
+		container.Mount{Host: gameDir, Container: "/game"},
+	)
+
+	spec := container.RunSpec{
+		Name:    name,
+		UID:     l.user.Uid,
+		GID:     l.user.Gid,
+		Env:     env,
+		Devices: devices,
+		Mounts:  mounts,
 		// CDDA seems to expect PWD to be the game root dir.
-		"-w", "/game",
-		"houseabsolute/catalauncher-player:latest",
-		"./cataclysm-tiles",
-		"--savedir", "/data/save/",
-		"--configdir", "/data/config/",
-		"--memorialdir", "/data/graveyard/",
+		Workdir: "/game",
+		Image:   image,
+		Command: []string{
+			"./cataclysm-tiles",
+			"--savedir", "/data/save/",
+			"--configdir", "/data/config/",
+			"--memorialdir", "/data/graveyard/",
+		},
 	}
 
-	err = l.runCommand("docker", args)
+	// We look up what to pin now in case the image is replaced while the
+	// game runs.
+	ref, err := l.imageRef(image)
 	if err != nil {
 		return err
 	}
-	os.Exit(0)
 
-	// We should never get here for obvious reasons
+	err = l.runGame("", l.runtime.Executable(), l.runtime.RunArgs(spec), func(*exec.Cmd, <-chan struct{}) error {
+		return l.stopContainer(name)
+	})
+	l.reportContainerState(name)
+	if err != nil {
+		return err
+	}
+
+	return l.pinImage(num, ref)
+}
+
+// hostResources returns the environment variables, devices, and mounts to
+// pass to the game's container. Resources that don't exist on the host are
+// left out, so the game runs without sound on a system without PulseAudio,
+// for example, rather than failing to start. The "[mounts]" section of the
+// config file can force resources to be included or excluded.
+//
+// The environment tells SDL which video and audio drivers to use based on
+// the resources that are passed to the container.
+func (l *Launcher) hostResources() ([]string, []string, []container.Mount, error) {
+	host, err := hostenv.Detect(l.user.Uid, l.user.HomeDir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	include, err := mountNames(l.config.IncludedMounts(), "include")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	exclude, err := mountNames(l.config.ExcludedMounts(), "exclude")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	waylandUsable := (host.WaylandSocket != "" || include[hostenv.WaylandResource]) &&
+		!exclude[hostenv.WaylandResource] && !l.config.ForceX11()
+
+	devices := []string{}
+	mounts := []container.Mount{}
+	used := map[string]bool{}
+	for _, r := range host.Resources() {
+		switch {
+		case exclude[r.Name]:
+			util.Say(l.stdout, "Not passing %s to the container because it is excluded in the config file", r.Path)
+			continue
+		case r.Name == hostenv.WaylandResource && l.config.ForceX11():
+			if r.Found {
+				util.Say(l.stdout, "Using X11 instead of Wayland because force_x11 is set in the config file")
+			}
+			continue
+		case !r.Found && include[r.Name]:
+			util.Say(l.stdout, "Passing %s to the container even though it was not found because it is included in the config file", r.Path)
+		case !r.Found:
+			// Wayland is optional and X11 isn't needed if the game can
+			// use Wayland, so their absence isn't worth mentioning.
+			if optionalResources[r.Name] {
+				continue
+			}
+			if r.Name == hostenv.X11SocketsResource && waylandUsable {
+				continue
+			}
+			if r.Name == hostenv.PulseResource && host.PipeWireSocket != "" {
+				util.Say(
+					l.stderr,
+					"%s does not exist, so %s. PipeWire is running, so installing pipewire-pulse should fix this",
+					r.Path, r.Purpose,
+				)
+				continue
+			}
+			util.Say(l.stderr, "%s does not exist, so %s", r.Path, r.Purpose)
+			continue
+		}
+
+		used[r.Name] = true
+		if r.Device {
+			devices = append(devices, r.Path)
+		} else {
+			mounts = append(mounts, container.Mount{Host: r.Path, Container: r.Container})
+		}
+	}
+
+	env := []string{"DISPLAY"}
+
+	// The Wayland socket and the PulseAudio directory are both mounted at
+	// the same path in the container as on the host, so clients can find
+	// them through $XDG_RUNTIME_DIR.
+	if used[hostenv.WaylandResource] || used[hostenv.PulseResource] {
+		env = append(env, "XDG_RUNTIME_DIR="+hostenv.RuntimeDirFor(l.user.Uid))
+	}
+
+	if used[hostenv.WaylandResource] {
+		env = append(env, "WAYLAND_DISPLAY="+hostenv.WaylandSocketFor(l.user.Uid), "SDL_VIDEODRIVER=wayland")
+	} else {
+		env = append(env, "SDL_VIDEODRIVER=x11")
+	}
+
+	// The SDL in the game's image is older than SDL's PipeWire driver, so on
+	// a PipeWire system sound goes through pipewire-pulse, which serves the
+	// PulseAudio socket in the same directory PulseAudio would.
+	if used[hostenv.PulseResource] {
+		env = append(env, "SDL_AUDIODRIVER=pulseaudio")
+	} else {
+		env = append(env, "SDL_AUDIODRIVER=dummy")
+	}
+
+	return env, devices, mounts, nil
+}
+
+var optionalResources = map[string]bool{
+	hostenv.WaylandResource: true,
+}
+
+func mountNames(names []string, key string) (map[string]bool, error) {
+	known := map[string]bool{}
+	for _, n := range hostenv.ResourceNames() {
+		known[n] = true
+	}
+
+	m := map[string]bool{}
+	for _, n := range names {
+		if !known[n] {
+			return nil, fmt.Errorf(
+				"Unknown mount %s in the %s key of the [mounts] config section, expected one of %s",
+				n, key, strings.Join(hostenv.ResourceNames(), ", "),
+			)
+		}
+		m[n] = true
+	}
+	return m, nil
+}
+
+// If a previous launch was killed before the runtime could remove the
+// container it may still be around, and its name would clash with ours.
+func (l *Launcher) removeStaleContainer(name string) error {
+	state, err := container.State(l.runtime, name)
+	if err != nil {
+		return err
+	}
+
+	switch state {
+	case "":
+		return nil
+	case "running":
+		return fmt.Errorf("The game is already running in the %s container", name)
+	default:
+		util.Say(l.stdout, "Removing the stale %s container left behind by a previous launch", name)
+		return l.runCommand(l.runtime.Executable(), l.runtime.RemoveArgs(name))
+	}
+}
+
+func (l *Launcher) stopContainer(name string) error {
+	return l.runCommand(l.runtime.Executable(), l.runtime.StopArgs(name, l.config.StopTimeout()))
+}
+
+// reportContainerState tells the user what happened to the container once
+// the game has exited, and removes it if it's still there.
+func (l *Launcher) reportContainerState(name string) {
+	state, err := container.State(l.runtime, name)
+	if err != nil {
+		util.Say(l.stderr, "Could not check the state of the %s container: %s", name, err)
+		return
+	}
+
+	if state == "" {
+		util.Say(l.stdout, "The %s container has been removed", name)
+		return
+	}
+
+	util.Say(l.stderr, "The %s container is still around (%s), removing it", name, state)
+	err = l.runCommand(l.runtime.Executable(), l.runtime.RemoveArgs(name))
+	if err != nil {
+		util.Say(l.stderr, "%s", err)
+	}
+}
+
+// checkNativeLibraries makes sure that the host has all the shared libraries
+// the game needs, since there's no image to provide them in native mode.
+func (l *Launcher) checkNativeLibraries(num uint) error {
+	gameDir, err := l.config.GameDir(num)
+	if err != nil {
+		return err
+	}
+
+	binary := filepath.Join(gameDir, config.GameBinary)
+	util.Say(l.stdout, "Checking that the shared libraries needed by %s are installed", binary)
+	missing, err := libcheck.Missing(binary)
+	if err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf(
+			"The game cannot be run natively because these shared libraries are missing:\n  %s\n"+
+				"Install them with your package manager (on Debian and Ubuntu these are the libsdl2 packages)\n"+
+				"or switch back to running the game in a container",
+			strings.Join(missing, "\n  "),
+		)
+	}
+
 	return nil
 }
 
-func (l *Launcher) runCommand(exe string, args []string) error {
+// launchNativeGame runs the game binary directly on the host with the same
+// data directories that would be mounted into a container.
+func (l *Launcher) launchNativeGame(gameDir, dataDir string) error {
+	args := []string{
+		"--savedir", filepath.Join(dataDir, "save") + "/",
+		"--configdir", filepath.Join(dataDir, "config") + "/",
+		"--memorialdir", filepath.Join(dataDir, "graveyard") + "/",
+	}
+
+	util.Say(l.stdout, "Launching the game natively from %s", gameDir)
+	// CDDA seems to expect PWD to be the game root dir.
+	return l.runGame(gameDir, "./"+config.GameBinary, args, l.stopNativeGame)
+}
+
+// stopNativeGame asks the game to exit and kills it if it hasn't after the
+// stop timeout. The exited channel is closed once the game has exited.
+func (l *Launcher) stopNativeGame(cmd *exec.Cmd, exited <-chan struct{}) error {
+	err := cmd.Process.Signal(syscall.SIGTERM)
+	if err != nil {
+		return fmt.Errorf("Could not send SIGTERM to the game: %s", err)
+	}
+
+	timer := time.NewTimer(l.config.StopTimeout())
+	defer timer.Stop()
+
+	select {
+	case <-exited:
+		return nil
+	case <-timer.C:
+	}
+
+	util.Say(l.stderr, "The game did not exit after %s, killing it", l.config.StopTimeout())
+	err = cmd.Process.Kill()
+	if err != nil {
+		return fmt.Errorf("Could not kill the game: %s", err)
+	}
+
+	return nil
+}
+
+// ExitError is returned when the game exits with a non-zero status.
+type ExitError struct {
+	Code int
+	// Stopped is true if the game was stopped because the launcher was
+	// interrupted.
+	Stopped bool
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("The game exited with status %d", e.Code)
+}
+
+// runGame runs the game and turns a non-zero exit into an ExitError so that
+// the launcher can exit with the same status.
+//
+// The game is run in its own process group so that a Ctrl-C in the terminal
+// only goes to the launcher. When the launcher gets SIGINT or SIGTERM it
+// calls stop, which should give the game a chance to save and exit. Stop is
+// passed a channel that is closed once the game has exited.
+func (l *Launcher) runGame(dir, exe string, args []string, stop func(*exec.Cmd, <-chan struct{}) error) error {
 	cmd := exec.Command(exe, args...)
-	out, err := cmd.CombinedOutput()
+	cmd.Dir = dir
+	cmd.Stdout = l.stdout
+	cmd.Stderr = l.stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	err := cmd.Start()
 	if err != nil {
-		msg := fmt.Sprintf("Could not run \"%s %s\": %s\n", exe, strings.Join(args, " "), err)
-		if len(out) > 0 {
-			msg += "\n" + string(out)
+		return fmt.Errorf("Could not run \"%s %s\": %s", exe, strings.Join(args, " "), err)
+	}
+
+	done := make(chan error, 1)
+	exited := make(chan struct{})
+	go func() {
+		err := cmd.Wait()
+		close(exited)
+		done <- err
+	}()
+
+	var received os.Signal
+wait:
+	for {
+		select {
+		case err = <-done:
+			break wait
+		case sig := <-sigs:
+			if received != nil {
+				util.Say(l.stderr, "Already stopping the game, please wait")
+				continue
+			}
+			received = sig
+			util.Say(
+				l.stderr,
+				"Got %s, stopping the game (waiting up to %s for it to exit)",
+				sig, l.config.StopTimeout(),
+			)
+			go func() {
+				if err := stop(cmd, exited); err != nil {
+					util.Say(l.stderr, "%s", err)
+				}
+			}()
 		}
-		return errors.New(msg)
+	}
+
+	if received != nil {
+		util.Say(l.stderr, "The game was stopped because the launcher got %s", received)
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() != 0 {
+		code := exitErr.ExitCode()
+		// A process killed by a signal has no exit code, so we use the same
+		// convention as the shell.
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			code = 128 + int(status.Signal())
+		}
+		return &ExitError{Code: code, Stopped: received != nil}
+	}
+	if err != nil {
+		return fmt.Errorf("Could not run \"%s %s\": %s", exe, strings.Join(args, " "), err)
+	}
+
+	util.Say(l.stdout, "The game exited cleanly")
+	return nil
+}
+
+// runCommand runs the command with its output going to the terminal and the
+// session log as it happens.
+func (l *Launcher) runCommand(exe string, args []string) error {
+	cmd := exec.Command(exe, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = l.stdout
+	cmd.Stderr = l.stderr
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("Could not run \"%s %s\": %w", exe, strings.Join(args, " "), err)
 	}
 
 	return nil
 }
 
+// startSessionLog tees everything the launcher and the commands it runs
+// print into a new log file under the LogsDir. The returned function closes
+// the log.
+func (l *Launcher) startSessionLog() (func(), error) {
+	err := l.mkdir(l.config.LogsDir())
+	if err != nil {
+		return nil, err
+	}
+
+	file := filepath.Join(l.config.LogsDir(), time.Now().Format("20060102-150405")+".log")
+	log, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Could not create file at %s: %s", file, err)
+	}
+
+	l.log = log
+	l.stdout = io.MultiWriter(l.stdout, log)
+	l.stderr = io.MultiWriter(l.stderr, log)
+	util.Say(l.stdout, "Logging this session to %s", file)
+
+	return func() { log.Close() }, nil
+}
+
 func (l *Launcher) mkdir(dir string) error {
 	err := os.MkdirAll(dir, 0755)
 	if err != nil {
@@ -0,0 +1,155 @@
+package libcheck
+
+import (
+	"bufio"
+	"debug/elf"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// These are searched after everything else, just like the dynamic linker
+// does.
+var defaultDirs = []string{
+	"/lib",
+	"/usr/lib",
+	"/lib64",
+	"/usr/lib64",
+	"/lib/x86_64-linux-gnu",
+	"/usr/lib/x86_64-linux-gnu",
+	"/usr/local/lib",
+}
+
+// Missing returns the shared libraries that the ELF binary at path needs
+// (its DT_NEEDED entries) which cannot be found in any of the directories
+// the dynamic linker would look in. It only checks the binary's direct
+// dependencies.
+func Missing(path string) ([]string, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read %s as an ELF binary: %s", path, err)
+	}
+	defer f.Close()
+
+	needed, err := f.ImportedLibraries()
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the shared libraries needed by %s: %s", path, err)
+	}
+
+	dirs, err := searchDirs(f, path)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := []string{}
+	for _, lib := range needed {
+		if !found(lib, dirs) {
+			missing = append(missing, lib)
+		}
+	}
+
+	return missing, nil
+}
+
+func found(lib string, dirs []string) bool {
+	if strings.Contains(lib, "/") {
+		_, err := os.Stat(lib)
+		return err == nil
+	}
+
+	for _, d := range dirs {
+		if _, err := os.Stat(filepath.Join(d, lib)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// searchDirs returns the directories to look for libraries in, in roughly
+// the same order as ld.so: RPATH, LD_LIBRARY_PATH, RUNPATH, ld.so.conf, and
+// then the default dirs.
+func searchDirs(f *elf.File, path string) ([]string, error) {
+	origin := filepath.Dir(path)
+
+	rpath, err := f.DynString(elf.DT_RPATH)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the RPATH of %s: %s", path, err)
+	}
+	runpath, err := f.DynString(elf.DT_RUNPATH)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the RUNPATH of %s: %s", path, err)
+	}
+
+	dirs := []string{}
+	// RPATH is ignored when RUNPATH is set.
+	if len(runpath) == 0 {
+		dirs = append(dirs, splitPath(rpath, origin)...)
+	}
+	dirs = append(dirs, splitPath([]string{os.Getenv("LD_LIBRARY_PATH")}, origin)...)
+	dirs = append(dirs, splitPath(runpath, origin)...)
+	dirs = append(dirs, ldSoConfDirs("/etc/ld.so.conf", map[string]bool{})...)
+	dirs = append(dirs, defaultDirs...)
+
+	return dirs, nil
+}
+
+func splitPath(paths []string, origin string) []string {
+	dirs := []string{}
+	for _, p := range paths {
+		for _, d := range strings.Split(p, ":") {
+			if d == "" {
+				continue
+			}
+			d = strings.Replace(d, "${ORIGIN}", origin, -1)
+			d = strings.Replace(d, "$ORIGIN", origin, -1)
+			dirs = append(dirs, d)
+		}
+	}
+	return dirs
+}
+
+// ldSoConfDirs returns the directories listed in an ld.so.conf file,
+// following any include directives. Errors are ignored since a missing or
+// unreadable file just means there are no extra dirs to look in.
+func ldSoConfDirs(file string, seen map[string]bool) []string {
+	if seen[file] {
+		return nil
+	}
+	seen[file] = true
+
+	fh, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer fh.Close()
+
+	dirs := []string{}
+	sc := bufio.NewScanner(fh)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "include ") {
+			pattern := strings.TrimSpace(strings.TrimPrefix(line, "include "))
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(filepath.Dir(file), pattern)
+			}
+			matches, _ := filepath.Glob(pattern)
+			for _, m := range matches {
+				dirs = append(dirs, ldSoConfDirs(m, seen)...)
+			}
+			continue
+		}
+
+		dirs = append(dirs, line)
+	}
+
+	return dirs
+}
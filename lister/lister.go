@@ -0,0 +1,261 @@
+package lister
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/houseabsolute/catalauncher/config"
+	"github.com/houseabsolute/catalauncher/localbuilds"
+	"github.com/houseabsolute/catalauncher/source"
+	"github.com/houseabsolute/catalauncher/util"
+)
+
+type Lister struct {
+	config *config.Config
+	local  *localbuilds.LocalBuilds
+	remote bool
+	asJSON bool
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func New(rootDir string, remote, asJSON bool) (*Lister, error) {
+	c, err := config.New(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Lister{
+		config: c,
+		local:  localbuilds.New(c),
+		remote: remote,
+		asJSON: asJSON,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}, nil
+}
+
+type installedBuild struct {
+	Number       uint       `json:"number"`
+	Version      string     `json:"version,omitempty"`
+	ReleaseDate  *time.Time `json:"release_date,omitempty"`
+	InstalledAt  *time.Time `json:"installed_at,omitempty"`
+	LastLaunched *time.Time `json:"last_launched,omitempty"`
+	DiskUsage    int64      `json:"disk_usage"`
+	Pinned       bool       `json:"pinned"`
+}
+
+type remoteBuild struct {
+	Number      uint      `json:"number"`
+	Version     string    `json:"version"`
+	ReleaseDate time.Time `json:"release_date"`
+	Installed   bool      `json:"installed"`
+	Latest      bool      `json:"latest"`
+	Pinned      bool      `json:"pinned"`
+}
+
+type listing struct {
+	Installed []installedBuild `json:"installed"`
+	Remote    []remoteBuild    `json:"remote,omitempty"`
+}
+
+func (l *Lister) List() error {
+	pinned := map[uint]bool{}
+	for _, p := range l.config.PinnedBuilds() {
+		pinned[p] = true
+	}
+
+	installed, err := l.installedBuilds(pinned)
+	if err != nil {
+		return err
+	}
+	list := listing{Installed: installed}
+
+	if l.remote {
+		remote, err := l.remoteBuilds(pinned)
+		if err != nil {
+			return err
+		}
+		list.Remote = remote
+	}
+
+	if l.asJSON {
+		out, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			return fmt.Errorf("Could not encode the build list as JSON: %s", err)
+		}
+		util.Say(l.stdout, string(out))
+		return nil
+	}
+
+	l.printInstalled(list.Installed)
+	if l.remote {
+		util.Say(l.stdout, "")
+		l.printRemote(list.Remote)
+	}
+
+	return nil
+}
+
+func (l *Lister) installedBuilds(pinned map[uint]bool) ([]installedBuild, error) {
+	all, err := l.local.All()
+	if err != nil {
+		return nil, err
+	}
+
+	builds := []installedBuild{}
+	// We show the most recent builds first, just like the remote list.
+	for i := len(all) - 1; i >= 0; i-- {
+		m, err := l.local.Get(all[i])
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := l.local.DiskUsage(all[i])
+		if err != nil {
+			return nil, err
+		}
+
+		builds = append(builds, installedBuild{
+			Number:       m.Number,
+			Version:      m.Version,
+			ReleaseDate:  timeOrNil(m.ReleaseDate),
+			InstalledAt:  timeOrNil(m.InstalledAt),
+			LastLaunched: timeOrNil(m.LastLaunched),
+			DiskUsage:    size,
+			Pinned:       pinned[m.Number],
+		})
+	}
+
+	return builds, nil
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func (l *Lister) remoteBuilds(pinned map[uint]bool) ([]remoteBuild, error) {
+	src, err := source.New(l.config)
+	if err != nil {
+		return nil, err
+	}
+
+	if !l.asJSON {
+		util.Say(l.stdout, "Getting list of builds from %s", src.Name())
+	}
+	available, err := src.Builds()
+	if err != nil {
+		return nil, err
+	}
+
+	builds := []remoteBuild{}
+	for i, b := range available {
+		installed, err := l.local.HasBuild(b.Number)
+		if err != nil {
+			return nil, err
+		}
+
+		builds = append(builds, remoteBuild{
+			Number:      b.Number,
+			Version:     b.Version,
+			ReleaseDate: b.Date,
+			Installed:   installed,
+			Latest:      i == 0,
+			Pinned:      pinned[b.Number],
+		})
+	}
+
+	return builds, nil
+}
+
+func (l *Lister) printInstalled(builds []installedBuild) {
+	if len(builds) == 0 {
+		util.Say(l.stdout, "No builds have been downloaded yet")
+		return
+	}
+
+	util.Say(l.stdout, "Installed builds:")
+	w := tabwriter.NewWriter(l.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BUILD\tVERSION\tRELEASED\tSIZE\tLAST LAUNCHED\t")
+	for _, b := range builds {
+		fmt.Fprintf(
+			w, "%s\t%s\t%s\t%s\t%s\t\n",
+			buildLabel(b.Number, b.Pinned, false),
+			orDash(b.Version),
+			formatTime(b.ReleaseDate),
+			humanSize(b.DiskUsage),
+			formatTime(b.LastLaunched),
+		)
+	}
+	w.Flush()
+}
+
+func (l *Lister) printRemote(builds []remoteBuild) {
+	if len(builds) == 0 {
+		util.Say(l.stdout, "Could not find any builds!")
+		return
+	}
+
+	util.Say(l.stdout, "Available builds:")
+	w := tabwriter.NewWriter(l.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BUILD\tVERSION\tRELEASED\tINSTALLED\t")
+	for _, b := range builds {
+		installed := ""
+		if b.Installed {
+			installed = "yes"
+		}
+		fmt.Fprintf(
+			w, "%s\t%s\t%s\t%s\t\n",
+			buildLabel(b.Number, b.Pinned, b.Latest),
+			b.Version,
+			formatTime(&b.ReleaseDate),
+			installed,
+		)
+	}
+	w.Flush()
+}
+
+func buildLabel(num uint, pinned, latest bool) string {
+	label := fmt.Sprintf("#%d", num)
+	if latest {
+		label += " (latest)"
+	}
+	if pinned {
+		label += " (pinned)"
+	}
+	return label
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func humanSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -4,11 +4,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
+	"time"
 
 	"github.com/houseabsolute/catalauncher/config"
+	"github.com/houseabsolute/catalauncher/util"
 )
 
 type LocalBuilds struct {
@@ -79,3 +82,103 @@ func (l *LocalBuilds) HasBuild(wanted uint) (bool, error) {
 	}
 	return false, nil
 }
+
+// Get returns the manifest for an installed build. It returns an error if the
+// build is not installed.
+func (l *LocalBuilds) Get(num uint) (*Manifest, error) {
+	// We don't use HasBuild because the list it checks is cached, and this
+	// may be called for a build that was installed after the cache was
+	// populated.
+	exists, err := util.PathExists(l.config.BuildDir(num))
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("Build #%d is not installed", num)
+	}
+
+	m, err := ReadManifest(l.config.BuildDir(num))
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return &Manifest{Number: num}, nil
+	}
+
+	// The directory name is what every other part of the launcher uses so we
+	// trust it over whatever is in the file.
+	m.Number = num
+
+	return m, nil
+}
+
+// MarkLaunched records that the build was just launched in its manifest.
+func (l *LocalBuilds) MarkLaunched(num uint) error {
+	m, err := l.Get(num)
+	if err != nil {
+		return err
+	}
+
+	m.LastLaunched = time.Now()
+
+	return WriteManifest(l.config.BuildDir(num), m)
+}
+
+// PinImage records an image that the build is known to work with in its
+// manifest.
+func (l *LocalBuilds) PinImage(num uint, image string) error {
+	m, err := l.Get(num)
+	if err != nil {
+		return err
+	}
+
+	m.Image = image
+
+	return WriteManifest(l.config.BuildDir(num), m)
+}
+
+// SetExtras records the commits of the extras that were copied into the
+// build in its manifest.
+func (l *LocalBuilds) SetExtras(num uint, commits []ExtrasCommit) error {
+	m, err := l.Get(num)
+	if err != nil {
+		return err
+	}
+
+	m.Extras = commits
+
+	return WriteManifest(l.config.BuildDir(num), m)
+}
+
+// SetMods records the mod directories that were copied into the build in its
+// manifest.
+func (l *LocalBuilds) SetMods(num uint, dirs []string) error {
+	m, err := l.Get(num)
+	if err != nil {
+		return err
+	}
+
+	m.Mods = dirs
+
+	return WriteManifest(l.config.BuildDir(num), m)
+}
+
+// DiskUsage returns the total size of the files in the build's directory.
+func (l *LocalBuilds) DiskUsage(num uint) (int64, error) {
+	var size int64
+	dir := l.config.BuildDir(num)
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Could not get the size of %s: %s", dir, err)
+	}
+
+	return size, nil
+}
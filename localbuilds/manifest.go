@@ -0,0 +1,129 @@
+package localbuilds
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/houseabsolute/catalauncher/util"
+)
+
+// ManifestFile is the name of the file in each build's directory that
+// records what we know about the build.
+const ManifestFile = "build.json"
+
+// Manifest is what we record about a build when it's installed. Builds
+// installed before manifests were written only have their Number set.
+type Manifest struct {
+	Number uint `json:"number"`
+	// Version is the version string from the build source, like
+	// "0.E-Linux_x64-Tiles-10478" or "cdda-experimental-2021-01-31-1234".
+	Version string `json:"version"`
+	// Variant is the kind of build, like "linux-tiles-x64".
+	Variant string `json:"variant"`
+	// ReleaseDate is when the build source says the build was released.
+	ReleaseDate time.Time `json:"release_date"`
+	// Source is the build source the build came from.
+	Source string `json:"source"`
+	// SourceURL is the URL the archive was downloaded from.
+	SourceURL string `json:"source_url"`
+	// ChangesURL is where the changes in this build are listed, if the source
+	// has such a thing.
+	ChangesURL string `json:"changes_url,omitempty"`
+	// Image is the container image the build last exited cleanly with. This
+	// is a repo digest for pulled images and an id for locally built ones.
+	Image string `json:"image,omitempty"`
+	// Extras is the commit of each extras source that was last copied into
+	// the build.
+	Extras []ExtrasCommit `json:"extras,omitempty"`
+	// Mods are the directories under data/mods in the game directory that
+	// were copied from the extras.
+	Mods []string `json:"mods,omitempty"`
+	// InstalledAt is when the build was installed.
+	InstalledAt time.Time `json:"installed_at"`
+	// LastLaunched is when the build was last launched.
+	LastLaunched time.Time `json:"last_launched"`
+	// Archive is the filename of the archive the build was installed from.
+	Archive string `json:"archive"`
+	// ArchiveSHA256 is the verified digest of that archive.
+	ArchiveSHA256 string `json:"archive_sha256"`
+	// Files maps the path of each file extracted from the archive, relative
+	// to the build directory, to its SHA-256 digest.
+	Files map[string]string `json:"files"`
+}
+
+// ExtrasCommit is the commit of an extras source.
+type ExtrasCommit struct {
+	URL    string `json:"url"`
+	Commit string `json:"commit"`
+}
+
+// ReadManifest reads the manifest in the given build directory. If there is
+// no manifest it returns nil.
+func ReadManifest(dir string) (*Manifest, error) {
+	file := filepath.Join(dir, ManifestFile)
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("Could not read %s: %s", file, err)
+	}
+
+	var m Manifest
+	err = json.Unmarshal(content, &m)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse %s as JSON: %s", file, err)
+	}
+
+	return &m, nil
+}
+
+// WriteManifest writes the manifest to the given build directory.
+func WriteManifest(dir string, m *Manifest) error {
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Could not encode the build manifest as JSON: %s", err)
+	}
+
+	file := filepath.Join(dir, ManifestFile)
+	return util.WriteFileAtomically(file, content)
+}
+
+// HashFiles returns a map of the path of each regular file under dir,
+// relative to dir, to its SHA-256 digest. The manifest itself is skipped.
+func HashFiles(dir string) (map[string]string, error) {
+	files := map[string]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == ManifestFile {
+			return nil
+		}
+
+		sum, err := util.FileSHA256(path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = sum
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Could not compute checksums for the files in %s: %s", dir, err)
+	}
+
+	return files, nil
+}
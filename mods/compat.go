@@ -0,0 +1,195 @@
+package mods
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/houseabsolute/catalauncher/util"
+)
+
+// Session statuses recorded for each mod and build.
+const (
+	Clean   = "clean"
+	Crashed = "crashed"
+)
+
+// Result is how the last session with a mod enabled on a build ended.
+type Result struct {
+	Status   string    `json:"status"`
+	ExitCode int       `json:"exit_code"`
+	Time     time.Time `json:"time"`
+}
+
+// compat maps mod ids to build numbers to the result of the last session
+// with that mod and build.
+type compat struct {
+	Mods map[string]map[uint]*Result `json:"mods"`
+}
+
+// readCompat reads the results file. These results are only used for
+// warnings, so if the file can't be read we warn and start over rather than
+// refusing to launch the game.
+func (m *Mods) readCompat() *compat {
+	empty := &compat{Mods: map[string]map[uint]*Result{}}
+
+	file := m.config.ModCompatFile()
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			util.Say(m.stderr, "Could not read %s, so ignoring the recorded mod results: %s", file, err)
+		}
+		return empty
+	}
+
+	c := &compat{}
+	err = json.Unmarshal(content, c)
+	if err != nil {
+		util.Say(m.stderr, "Could not parse %s as JSON, so ignoring the recorded mod results: %s", file, err)
+		return empty
+	}
+	if c.Mods == nil {
+		c.Mods = map[string]map[uint]*Result{}
+	}
+
+	return c
+}
+
+func (m *Mods) writeCompat(c *compat) error {
+	content, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Could not encode the mod compatibility results as JSON: %s", err)
+	}
+
+	return util.WriteFileAtomically(m.config.ModCompatFile(), append(content, '\n'))
+}
+
+// RecordSession records how a session of the given build with the given mods
+// ended. An exit code of 0 is a clean exit and anything else is a crash.
+// Since we can't tell which mod crashed the game, every mod is marked as
+// crashing.
+func (m *Mods) RecordSession(num uint, ids []string, exitCode int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	c := m.readCompat()
+	status := Clean
+	if exitCode != 0 {
+		status = Crashed
+		util.Say(
+			m.stderr, "Marking these mods as crashing on build #%d: %s",
+			num, strings.Join(ids, ", "),
+		)
+	}
+
+	now := time.Now()
+	for _, id := range ids {
+		if c.Mods[id] == nil {
+			c.Mods[id] = map[uint]*Result{}
+		}
+		c.Mods[id][num] = &Result{Status: status, ExitCode: exitCode, Time: now}
+	}
+
+	return m.writeCompat(c)
+}
+
+// Crashing returns the ids of the mods whose last session on the given build
+// crashed, sorted by id.
+func (m *Mods) Crashing(num uint) []string {
+	c := m.readCompat()
+	crashing := []string{}
+	for id, builds := range c.Mods {
+		if r := builds[num]; r != nil && r.Status == Crashed {
+			crashing = append(crashing, id)
+		}
+	}
+	sort.Strings(crashing)
+
+	return crashing
+}
+
+// ClearCompat forgets the recorded results for the given mods, so that a mod
+// that crashed the game is no longer excluded or warned about. If num is not
+// 0 then only the results for that build are forgotten.
+func (m *Mods) ClearCompat(ids []string, num uint) error {
+	c := m.readCompat()
+	for _, id := range ids {
+		results := c.Mods[id]
+		if num != 0 {
+			if results[num] == nil {
+				util.Say(m.stderr, "There are no results recorded for the %s mod on build #%d", id, num)
+				continue
+			}
+			delete(results, num)
+			if len(results) == 0 {
+				delete(c.Mods, id)
+			}
+			util.Say(m.stdout, "Cleared the results for the %s mod on build #%d", id, num)
+			continue
+		}
+
+		if len(results) == 0 {
+			util.Say(m.stderr, "There are no results recorded for the %s mod", id)
+			continue
+		}
+		delete(c.Mods, id)
+		util.Say(m.stdout, "Cleared the results for the %s mod", id)
+	}
+
+	return m.writeCompat(c)
+}
+
+// Compat prints a matrix of how the last session with each mod on each build
+// ended.
+func (m *Mods) Compat() error {
+	c := m.readCompat()
+	if len(c.Mods) == 0 {
+		util.Say(m.stdout, "No sessions with mods from the extras have been recorded yet")
+		return nil
+	}
+
+	ids := []string{}
+	seen := map[uint]bool{}
+	builds := []uint{}
+	for id, results := range c.Mods {
+		ids = append(ids, id)
+		for num := range results {
+			if !seen[num] {
+				seen[num] = true
+				builds = append(builds, num)
+			}
+		}
+	}
+	sort.Strings(ids)
+	sort.Slice(builds, func(i, j int) bool { return builds[i] < builds[j] })
+
+	w := tabwriter.NewWriter(m.stdout, 0, 0, 2, ' ', 0)
+	header := []string{"MOD"}
+	for _, num := range builds {
+		header = append(header, fmt.Sprintf("#%d", num))
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t")+"\t")
+
+	for _, id := range ids {
+		row := []string{id}
+		for _, num := range builds {
+			cell := "-"
+			if r := c.Mods[id][num]; r != nil {
+				cell = r.Status
+				if r.Status == Crashed {
+					cell = fmt.Sprintf("%s (%d)", r.Status, r.ExitCode)
+				}
+			}
+			row = append(row, cell)
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t")+"\t")
+	}
+
+	return w.Flush()
+}
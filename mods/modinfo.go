@@ -0,0 +1,166 @@
+package mods
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/houseabsolute/catalauncher/util"
+)
+
+// ModInfoFile is the file in each mod's directory that describes the mod.
+const ModInfoFile = "modinfo.json"
+
+// Mod is a mod found in the game or the extras.
+type Mod struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Dependencies []string `json:"dependencies"`
+	// Obsolete is true if the game considers the mod obsolete. The game
+	// hides obsolete mods when creating a new world.
+	Obsolete bool `json:"obsolete"`
+	// Source is where the mod comes from. This is "game" for mods that ship
+	// with the game and the URL of the extras source otherwise.
+	Source string `json:"source"`
+	// Dir is the mod's directory.
+	Dir string `json:"-"`
+}
+
+// GameSource is the Source for mods that ship with the game.
+const GameSource = "game"
+
+// BuiltIn returns true if the mod ships with the game.
+func (m *Mod) BuiltIn() bool {
+	return m.Source == GameSource
+}
+
+// modInfo is the part of a MOD_INFO object that we care about.
+type modInfo struct {
+	Type         string          `json:"type"`
+	ID           string          `json:"id"`
+	Ident        string          `json:"ident"`
+	Name         json.RawMessage `json:"name"`
+	Dependencies []string        `json:"dependencies"`
+	Obsolete     bool            `json:"obsolete"`
+}
+
+// readModInfo parses the modinfo.json file in dir. The file usually contains
+// an array of objects, one of which has the type "MOD_INFO", but some older
+// mods have just the one object.
+func readModInfo(dir string) (*Mod, error) {
+	file := filepath.Join(dir, ModInfoFile)
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read %s: %s", file, err)
+	}
+
+	infos := []modInfo{}
+	trimmed := strings.TrimSpace(string(content))
+	if strings.HasPrefix(trimmed, "{") {
+		info := modInfo{}
+		err = json.Unmarshal(content, &info)
+		infos = append(infos, info)
+	} else {
+		err = json.Unmarshal(content, &infos)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse %s as JSON: %s", file, err)
+	}
+
+	for _, info := range infos {
+		if !strings.EqualFold(info.Type, "MOD_INFO") {
+			continue
+		}
+
+		id := info.ID
+		// Old mods used "ident" instead of "id".
+		if id == "" {
+			id = info.Ident
+		}
+		if id == "" {
+			return nil, fmt.Errorf("The MOD_INFO in %s does not have an id", file)
+		}
+
+		name := modName(info.Name)
+		if name == "" {
+			name = id
+		}
+
+		deps := info.Dependencies
+		if deps == nil {
+			deps = []string{}
+		}
+
+		return &Mod{
+			ID:           id,
+			Name:         name,
+			Dependencies: deps,
+			Obsolete:     info.Obsolete,
+			Dir:          dir,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("There is no MOD_INFO in %s", file)
+}
+
+// The name is usually a string but it can also be a translation object like
+// {"str": "Name"}.
+func modName(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+
+	var name string
+	if err := json.Unmarshal(raw, &name); err == nil {
+		return name
+	}
+
+	var translation struct {
+		Str string `json:"str"`
+	}
+	if err := json.Unmarshal(raw, &translation); err == nil {
+		return translation.Str
+	}
+
+	return ""
+}
+
+// findMods returns every mod under dir. A mod is a directory containing a
+// modinfo.json file. Mods may be nested in other directories, but not in
+// other mods.
+func findMods(dir string) ([]string, error) {
+	dirs := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == dir && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		exists, err := util.PathExists(filepath.Join(path, ModInfoFile))
+		if err != nil {
+			return err
+		}
+		if exists {
+			dirs = append(dirs, path)
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Could not look for mods in %s: %s", dir, err)
+	}
+
+	return dirs, nil
+}
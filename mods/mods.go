@@ -0,0 +1,481 @@
+package mods
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/houseabsolute/catalauncher/config"
+	"github.com/houseabsolute/catalauncher/localbuilds"
+	"github.com/houseabsolute/catalauncher/util"
+	"github.com/otiai10/copy"
+)
+
+// Mods manages the mods provided by the extras sources. Only the mods that
+// are enabled, along with the mods they depend on, are copied into a build.
+type Mods struct {
+	config *config.Config
+	local  *localbuilds.LocalBuilds
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func New(c *config.Config) *Mods {
+	return &Mods{
+		config: c,
+		local:  localbuilds.New(c),
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}
+}
+
+// modsDir is where the game keeps its mods, relative to the game dir.
+const modsDir = "data/mods"
+
+// All returns every mod in the given build's game dir and in the extras,
+// keyed by id. If num is 0 then only the mods in the extras are returned. If
+// two extras sources provide a mod with the same id then the later source
+// wins, like it does for other extras.
+func (m *Mods) All(num uint) (map[string]*Mod, error) {
+	all := map[string]*Mod{}
+
+	if num != 0 {
+		gameDir, err := m.config.GameDir(num)
+		if err != nil {
+			return nil, err
+		}
+
+		err = m.addMods(all, filepath.Join(gameDir, modsDir), GameSource)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sources, err := m.config.ExtrasSources()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sources {
+		if s.Mods == "" {
+			continue
+		}
+		err := m.addMods(all, filepath.Join(m.config.ExtrasSourceDir(s), s.Mods), s.URL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return all, nil
+}
+
+func (m *Mods) addMods(all map[string]*Mod, dir, source string) error {
+	dirs, err := findMods(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range dirs {
+		mod, err := readModInfo(d)
+		if err != nil {
+			util.Say(m.stderr, "Skipping the mod in %s: %s", d, err)
+			continue
+		}
+		mod.Source = source
+
+		// Mods we've copied into a build look like they ship with the game,
+		// but they're really from the extras, which come after the game.
+		if other, ok := all[mod.ID]; ok && !other.BuiltIn() {
+			util.Say(
+				m.stderr, "Conflict: both %s and %s provide the %s mod, using the one from %s",
+				other.Dir, mod.Dir, mod.ID, mod.Dir,
+			)
+		}
+		all[mod.ID] = mod
+	}
+
+	return nil
+}
+
+type enabledMods struct {
+	Enabled []string `json:"enabled"`
+}
+
+// Enabled returns the ids of the mods that have been enabled.
+func (m *Mods) Enabled() ([]string, error) {
+	file := m.config.EnabledModsFile()
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("Could not read %s: %s", file, err)
+	}
+
+	e := enabledMods{}
+	err = json.Unmarshal(content, &e)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse %s as JSON: %s", file, err)
+	}
+	if e.Enabled == nil {
+		e.Enabled = []string{}
+	}
+
+	return e.Enabled, nil
+}
+
+func (m *Mods) setEnabled(ids []string) error {
+	sort.Strings(ids)
+	content, err := json.MarshalIndent(enabledMods{Enabled: ids}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Could not encode the enabled mods as JSON: %s", err)
+	}
+
+	return util.WriteFileAtomically(m.config.EnabledModsFile(), append(content, '\n'))
+}
+
+// Enable enables the mods with the given ids.
+func (m *Mods) Enable(ids []string) error {
+	latest, err := m.local.Latest()
+	if err != nil {
+		return err
+	}
+
+	all, err := m.All(latest)
+	if err != nil {
+		return err
+	}
+
+	enabled, err := m.Enabled()
+	if err != nil {
+		return err
+	}
+	isEnabled := map[string]bool{}
+	for _, id := range enabled {
+		isEnabled[id] = true
+	}
+
+	for _, id := range ids {
+		mod, ok := all[id]
+		switch {
+		case !ok:
+			return fmt.Errorf("There is no mod with the id %s. Run \"catalauncher mods list\" to see the available mods", id)
+		case mod.BuiltIn():
+			util.Say(m.stdout, "The %s mod ships with the game, so it is always available", id)
+			continue
+		case isEnabled[id]:
+			util.Say(m.stdout, "The %s mod is already enabled", id)
+			continue
+		}
+
+		if mod.Obsolete {
+			util.Say(m.stderr, "The %s mod is marked as obsolete, so the game may not let you use it in new worlds", id)
+		}
+		util.Say(m.stdout, "Enabled the %s mod", id)
+		enabled = append(enabled, id)
+		isEnabled[id] = true
+	}
+
+	_, missing := closure(all, enabled)
+	m.reportMissing(missing)
+
+	return m.setEnabled(enabled)
+}
+
+// Disable disables the mods with the given ids.
+func (m *Mods) Disable(ids []string) error {
+	enabled, err := m.Enabled()
+	if err != nil {
+		return err
+	}
+
+	remove := map[string]bool{}
+	for _, id := range ids {
+		remove[id] = true
+	}
+
+	kept := []string{}
+	for _, id := range enabled {
+		if remove[id] {
+			util.Say(m.stdout, "Disabled the %s mod", id)
+			delete(remove, id)
+			continue
+		}
+		kept = append(kept, id)
+	}
+	for _, id := range ids {
+		if remove[id] {
+			util.Say(m.stdout, "The %s mod is not enabled", id)
+		}
+	}
+
+	latest, err := m.local.Latest()
+	if err != nil {
+		return err
+	}
+	all, err := m.All(latest)
+	if err != nil {
+		return err
+	}
+
+	needed, _ := closure(all, kept)
+	for _, id := range ids {
+		if needed[id] != nil && !all[id].BuiltIn() {
+			util.Say(m.stdout, "The %s mod is still needed by another enabled mod, so it will still be copied into the game", id)
+		}
+	}
+
+	return m.setEnabled(kept)
+}
+
+// closure returns the enabled mods and every mod they depend on, keyed by
+// id. Mods that depend on a mod that doesn't exist are left out. The missing
+// dependencies are returned keyed by the id of the mod that needs them.
+func closure(all map[string]*Mod, enabled []string) (map[string]*Mod, map[string][]string) {
+	needed := map[string]*Mod{}
+	missing := map[string][]string{}
+	// This records whether each mod we've visited can be loaded.
+	ok := map[string]bool{}
+
+	var visit func(id string, seen map[string]bool) bool
+	visit = func(id string, seen map[string]bool) bool {
+		if result, done := ok[id]; done {
+			return result
+		}
+		mod, exists := all[id]
+		if !exists {
+			return false
+		}
+		// A dependency cycle is the game's problem, not ours.
+		if seen[id] {
+			return true
+		}
+		seen[id] = true
+
+		result := true
+		for _, dep := range mod.Dependencies {
+			if _, exists := all[dep]; !exists {
+				missing[id] = append(missing[id], dep)
+				result = false
+				continue
+			}
+			if !visit(dep, seen) {
+				result = false
+			}
+		}
+
+		ok[id] = result
+		if result {
+			needed[id] = mod
+		}
+		return result
+	}
+
+	for _, id := range enabled {
+		if _, exists := all[id]; !exists {
+			missing[id] = nil
+			continue
+		}
+		visit(id, map[string]bool{})
+	}
+
+	return needed, missing
+}
+
+func (m *Mods) reportMissing(missing map[string][]string) {
+	ids := []string{}
+	for id := range missing {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if len(missing[id]) == 0 {
+			util.Say(m.stderr, "The enabled mod %s could not be found in the extras", id)
+			continue
+		}
+		util.Say(
+			m.stderr, "The %s mod depends on %s, which is not available, so it will not be copied into the game",
+			id, strings.Join(missing[id], ", "),
+		)
+	}
+}
+
+// Install copies the enabled mods and their dependencies from the extras
+// into the build's game dir, and removes any mods that were copied into the
+// build before but are no longer needed. It returns the ids of the mods it
+// copied.
+//
+// Mods that crashed the game the last time they were used with this build
+// are reported, and if the config says so, they are not copied.
+func (m *Mods) Install(num uint) ([]string, error) {
+	gameDir, err := m.config.GameDir(num)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := m.local.Get(num)
+	if err != nil {
+		return nil, err
+	}
+
+	// We don't want to treat the mods we copied last time as part of the
+	// game.
+	target := filepath.Join(gameDir, modsDir)
+	for _, d := range manifest.Mods {
+		dir := filepath.Join(target, d)
+		err := os.RemoveAll(dir)
+		if err != nil {
+			return nil, fmt.Errorf("Could not remove %s: %s", dir, err)
+		}
+	}
+
+	all, err := m.All(num)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled, err := m.Enabled()
+	if err != nil {
+		return nil, err
+	}
+
+	needed, _ := closure(all, enabled)
+	for _, id := range m.Crashing(num) {
+		if needed[id] == nil {
+			continue
+		}
+		if m.config.ExcludeCrashingMods() {
+			util.Say(m.stderr, "Not copying the %s mod because the game crashed the last time it was used with build #%d", id, num)
+			delete(all, id)
+		} else {
+			util.Say(m.stderr, "Warning: the game crashed the last time the %s mod was used with build #%d", id, num)
+		}
+	}
+
+	needed, missing := closure(all, enabled)
+	m.reportMissing(missing)
+
+	ids := []string{}
+	for id, mod := range needed {
+		if !mod.BuiltIn() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	copied := []string{}
+	installed := []string{}
+	for _, id := range ids {
+		mod := needed[id]
+		name := filepath.Base(mod.Dir)
+		dest := filepath.Join(target, name)
+
+		exists, err := util.PathExists(dest)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			util.Say(m.stderr, "The game already has a %s directory, so the %s mod will not be copied", dest, id)
+			continue
+		}
+
+		util.Say(m.stdout, "Copying the %s mod to the game dir", id)
+		err = copy.Copy(mod.Dir, dest)
+		if err != nil {
+			return nil, fmt.Errorf("Could not copy %s to %s: %s", mod.Dir, dest, err)
+		}
+		copied = append(copied, name)
+		installed = append(installed, id)
+	}
+
+	return installed, m.local.SetMods(num, copied)
+}
+
+// List prints every mod in the given build and the extras. If num is 0 then
+// the latest local build is used.
+func (m *Mods) List(num uint) error {
+	if num == 0 {
+		latest, err := m.local.Latest()
+		if err != nil {
+			return err
+		}
+		num = latest
+	}
+
+	// The mods we copied into the build would otherwise show up twice.
+	copied := map[string]bool{}
+	if num != 0 {
+		manifest, err := m.local.Get(num)
+		if err != nil {
+			return err
+		}
+		for _, d := range manifest.Mods {
+			copied[d] = true
+		}
+	}
+
+	all, err := m.All(num)
+	if err != nil {
+		return err
+	}
+
+	enabled, err := m.Enabled()
+	if err != nil {
+		return err
+	}
+	isEnabled := map[string]bool{}
+	for _, id := range enabled {
+		isEnabled[id] = true
+	}
+	needed, _ := closure(all, enabled)
+
+	ids := []string{}
+	for id, mod := range all {
+		if mod.BuiltIn() && copied[filepath.Base(mod.Dir)] {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	if len(ids) == 0 {
+		util.Say(m.stdout, "There are no mods in the game or the extras")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(m.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSTATUS\tSOURCE\tDEPENDENCIES\t")
+	for _, id := range ids {
+		mod := all[id]
+
+		status := ""
+		switch {
+		case mod.BuiltIn():
+			status = "built in"
+		case isEnabled[id] && needed[id] == nil:
+			status = "missing dependencies"
+		case isEnabled[id]:
+			status = "enabled"
+		case needed[id] != nil:
+			status = "dependency"
+		}
+		if mod.Obsolete {
+			if status != "" {
+				status += ", "
+			}
+			status += "obsolete"
+		}
+
+		fmt.Fprintf(
+			w, "%s\t%s\t%s\t%s\t%s\t\n",
+			id, mod.Name, status, mod.Source, strings.Join(mod.Dependencies, ", "),
+		)
+	}
+	return w.Flush()
+}
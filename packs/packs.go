@@ -0,0 +1,340 @@
+package packs
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/houseabsolute/catalauncher/config"
+	"github.com/houseabsolute/catalauncher/localbuilds"
+	"github.com/houseabsolute/catalauncher/snapshotter"
+	"github.com/houseabsolute/catalauncher/util"
+)
+
+// Kind describes one kind of pack, like a tileset or soundpack.
+type Kind struct {
+	// Name is what the pack is called in messages.
+	Name string
+	// Dir is where the packs are in the game dir.
+	Dir string
+	// InfoFile is the file in each pack's directory that names the pack.
+	InfoFile string
+	// Option is the game option that selects the pack.
+	Option string
+}
+
+var (
+	Tileset = Kind{
+		Name:     "tileset",
+		Dir:      "gfx",
+		InfoFile: "tileset.txt",
+		Option:   "TILES",
+	}
+	Soundpack = Kind{
+		Name:     "soundpack",
+		Dir:      "data/sound",
+		InfoFile: "soundpack.txt",
+		Option:   "SOUNDPACKS",
+	}
+)
+
+// Packs lists the packs of one kind in a build and picks which one the game
+// uses.
+type Packs struct {
+	config *config.Config
+	local  *localbuilds.LocalBuilds
+	kind   Kind
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// Pack is a single tileset or soundpack.
+type Pack struct {
+	// Name is the pack's name as the game refers to it in its options.
+	Name string
+	// View is the pack's name as the game shows it to the player.
+	View string
+	Dir  string
+}
+
+func New(rootDir string, kind Kind) (*Packs, error) {
+	c, err := config.New(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Packs{
+		config: c,
+		local:  localbuilds.New(c),
+		kind:   kind,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}, nil
+}
+
+// Packs returns the packs in the given build, sorted by name. If num is 0
+// then the latest local build is used.
+func (p *Packs) Packs(num uint) ([]*Pack, error) {
+	if num == 0 {
+		latest, err := p.local.Latest()
+		if err != nil {
+			return nil, err
+		}
+		if latest == 0 {
+			return nil, fmt.Errorf("No builds have been downloaded yet, so there are no %ss", p.kind.Name)
+		}
+		num = latest
+	}
+
+	gameDir, err := p.config.GameDir(num)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(gameDir, p.kind.Dir)
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*Pack{}, nil
+		}
+		return nil, fmt.Errorf("Could not read directory %s: %s", dir, err)
+	}
+
+	packs := []*Pack{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		file := filepath.Join(dir, e.Name(), p.kind.InfoFile)
+		pack, err := readInfo(file)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				util.Say(p.stderr, "Skipping %s: %s", filepath.Join(dir, e.Name()), err)
+			}
+			continue
+		}
+		pack.Dir = filepath.Join(dir, e.Name())
+		packs = append(packs, pack)
+	}
+
+	sort.Slice(packs, func(i, j int) bool { return packs[i].Name < packs[j].Name })
+
+	return packs, nil
+}
+
+// readInfo parses a tileset.txt or soundpack.txt file. These contain lines
+// like "NAME: UltimateCataclysm" and "VIEW: Ultimate Cataclysm", and
+// comments starting with "#".
+func readInfo(file string) (*Pack, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pack := &Pack{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		switch strings.ToUpper(strings.TrimSpace(parts[0])) {
+		case "NAME":
+			pack.Name = value
+		case "VIEW":
+			pack.View = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Could not read %s: %s", file, err)
+	}
+
+	if pack.Name == "" {
+		return nil, fmt.Errorf("%s does not have a NAME", file)
+	}
+	if pack.View == "" {
+		pack.View = pack.Name
+	}
+
+	return pack, nil
+}
+
+// List prints the packs in the given build, marking the one the game is set
+// to use.
+func (p *Packs) List(num uint) error {
+	packs, err := p.Packs(num)
+	if err != nil {
+		return err
+	}
+
+	if len(packs) == 0 {
+		util.Say(p.stdout, "There are no %ss in the game", p.kind.Name)
+		return nil
+	}
+
+	current, err := p.current()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(p.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tDISPLAY NAME\tIN USE\t")
+	for _, pack := range packs {
+		inUse := ""
+		if pack.Name == current {
+			inUse = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t\n", pack.Name, pack.View, inUse)
+	}
+	return w.Flush()
+}
+
+// Use sets the game's option for this kind of pack to the named pack. The
+// name can be either the pack's name or the name the game displays. If num
+// is 0 then the packs in the latest local build are checked.
+func (p *Packs) Use(num uint, name string) error {
+	packs, err := p.Packs(num)
+	if err != nil {
+		return err
+	}
+
+	var pack *Pack
+	for _, candidate := range packs {
+		if strings.EqualFold(candidate.Name, name) || strings.EqualFold(candidate.View, name) {
+			pack = candidate
+			break
+		}
+	}
+	if pack == nil {
+		return fmt.Errorf(
+			"There is no %s named %s. Run \"catalauncher %s list\" to see the available %ss",
+			p.kind.Name, name, p.kind.Name, p.kind.Name,
+		)
+	}
+
+	// The game writes its options when it exits, which would undo our
+	// change.
+	running, err := snapshotter.GameIsRunning(p.config)
+	if err != nil {
+		return err
+	}
+	if running {
+		return fmt.Errorf("The game is running. Quit the game before changing the %s", p.kind.Name)
+	}
+
+	err = setOption(p.config.OptionsFile(), p.kind.Option, pack.Name)
+	if err != nil {
+		return err
+	}
+
+	util.Say(p.stdout, "The game will use the %s %s", pack.View, p.kind.Name)
+	return nil
+}
+
+func (p *Packs) current() (string, error) {
+	options, err := readOptions(p.config.OptionsFile())
+	if err != nil {
+		return "", err
+	}
+
+	for _, o := range options {
+		var opt struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		}
+		// Options we can't parse aren't the one we're looking for.
+		if json.Unmarshal(o, &opt) == nil && opt.Name == p.kind.Option {
+			return opt.Value, nil
+		}
+	}
+
+	return "", nil
+}
+
+// The options file is a JSON array of objects, each with a "name" and a
+// "value" as well as some other keys depending on the game version. We only
+// decode the option we're changing so that every other option is written
+// back exactly as it was.
+func readOptions(file string) ([]json.RawMessage, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []json.RawMessage{}, nil
+		}
+		return nil, fmt.Errorf("Could not read %s: %s", file, err)
+	}
+
+	options := []json.RawMessage{}
+	err = json.Unmarshal(content, &options)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse %s as JSON: %s", file, err)
+	}
+
+	return options, nil
+}
+
+func setOption(file, name, value string) error {
+	options, err := readOptions(file)
+	if err != nil {
+		return err
+	}
+
+	encodedValue, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i, o := range options {
+		opt := map[string]json.RawMessage{}
+		if json.Unmarshal(o, &opt) != nil {
+			continue
+		}
+
+		var optName string
+		if json.Unmarshal(opt["name"], &optName) != nil || optName != name {
+			continue
+		}
+
+		opt["value"] = encodedValue
+		options[i], err = json.Marshal(opt)
+		if err != nil {
+			return err
+		}
+		found = true
+		break
+	}
+
+	// The game fills in any options that are missing from the file with
+	// their defaults, so we only need the name and value.
+	if !found {
+		o, err := json.Marshal(map[string]string{"name": name, "value": value})
+		if err != nil {
+			return err
+		}
+		options = append(options, o)
+	}
+
+	content, err := json.MarshalIndent(options, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Could not encode the options as JSON: %s", err)
+	}
+
+	// The game must never see a half-written options file.
+	return util.WriteFileAtomically(file, append(content, '\n'))
+}
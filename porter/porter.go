@@ -0,0 +1,194 @@
+package porter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/houseabsolute/catalauncher/archiver"
+	"github.com/houseabsolute/catalauncher/config"
+	"github.com/houseabsolute/catalauncher/snapshotter"
+	"github.com/houseabsolute/catalauncher/util"
+)
+
+// Porter exports worlds to portable archives and imports them again.
+type Porter struct {
+	config *config.Config
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func New(rootDir string) (*Porter, error) {
+	c, err := config.New(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Porter{
+		config: c,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}, nil
+}
+
+// Every CDDA world directory has these files.
+var worldFiles = []string{"worldoptions.json", "master.gsav"}
+
+// Export writes the named world to a tar.gz archive at file. If file is empty
+// the archive is written to "<world>.tar.gz" in the current directory.
+func (p *Porter) Export(world, file string) error {
+	err := validateName(world)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(p.config.SaveDir(), world)
+	exists, err := util.PathExists(dir)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("There is no world named %s in %s", world, p.config.SaveDir())
+	}
+
+	err = checkWorld(dir)
+	if err != nil {
+		return fmt.Errorf("The %s world does not look like a CDDA world: %s", world, err)
+	}
+
+	if file == "" {
+		file = world + ".tar.gz"
+	}
+
+	util.Say(p.stdout, "Exporting %s to %s", world, file)
+	return archiver.Create(file, dir)
+}
+
+// Import adds the world in the archive at file to the save dir. If name is
+// not empty the world is renamed to that. An existing world with the same
+// name is only replaced if force is true.
+func (p *Porter) Import(file, name string, force bool) error {
+	err := os.MkdirAll(p.config.SaveDir(), 0755)
+	if err != nil {
+		return fmt.Errorf("Could not make directory %s: %s", p.config.SaveDir(), err)
+	}
+
+	// We extract into the save dir so that we can rename the result into
+	// place.
+	tmp, err := ioutil.TempDir(p.config.SaveDir(), ".import-")
+	if err != nil {
+		return fmt.Errorf("Could not create a temporary directory in %s: %s", p.config.SaveDir(), err)
+	}
+	defer os.RemoveAll(tmp)
+
+	// CDDA worlds never contain links, so an archive with links in it is
+	// either not a world export or has been tampered with.
+	err = archiver.ExtractWithoutLinks(file, tmp)
+	if err != nil {
+		return err
+	}
+
+	entries, err := ioutil.ReadDir(tmp)
+	if err != nil {
+		return fmt.Errorf("Could not read directory at %s: %s", tmp, err)
+	}
+	if len(entries) != 1 || !entries[0].IsDir() {
+		return fmt.Errorf("The archive at %s does not look like a world export, it should contain a single directory", file)
+	}
+
+	extracted := filepath.Join(tmp, entries[0].Name())
+	err = checkWorld(extracted)
+	if err != nil {
+		return fmt.Errorf("The archive at %s does not look like a world export: %s", file, err)
+	}
+
+	if name == "" {
+		name = entries[0].Name()
+	}
+	err = validateName(name)
+	if err != nil {
+		return err
+	}
+
+	target := filepath.Join(p.config.SaveDir(), name)
+	exists, err := util.PathExists(target)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if !force {
+			return fmt.Errorf("There is already a world named %s. Pass --force to replace it or --name to import it under a different name", name)
+		}
+
+		err = p.moveExistingWorld(name, target, tmp)
+		if err != nil {
+			return err
+		}
+	}
+
+	util.Say(p.stdout, "Importing %s as %s", file, name)
+	err = os.Rename(extracted, target)
+	if err != nil {
+		return fmt.Errorf("Could not move %s to %s: %s", extracted, target, err)
+	}
+
+	return nil
+}
+
+// We take a snapshot of a world before replacing it, just in case.
+func (p *Porter) moveExistingWorld(name, target, tmp string) error {
+	running, err := snapshotter.GameIsRunning(p.config)
+	if err != nil {
+		return err
+	}
+	if running {
+		return errors.New("The game is running. Quit the game before replacing a world")
+	}
+
+	s, err := snapshotter.New(p.config.RootDir())
+	if err != nil {
+		return err
+	}
+	util.Say(p.stdout, "Taking a snapshot of the existing %s world before replacing it", name)
+	_, err = s.Snapshot(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(target, filepath.Join(tmp, ".previous"))
+	if err != nil {
+		return fmt.Errorf("Could not move %s out of the way: %s", target, err)
+	}
+
+	return nil
+}
+
+func checkWorld(dir string) error {
+	missing := []string{}
+	for _, f := range worldFiles {
+		exists, err := util.PathExists(filepath.Join(dir, f))
+		if err != nil {
+			return err
+		}
+		if !exists {
+			missing = append(missing, f)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("it is missing %s", strings.Join(missing, " and "))
+	}
+
+	return nil
+}
+
+func validateName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("%s is not a valid world name", name)
+	}
+	return nil
+}
@@ -3,10 +3,11 @@ package setupper
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"github.com/houseabsolute/catalauncher/config"
+	"github.com/houseabsolute/catalauncher/container"
 	"github.com/houseabsolute/catalauncher/curuser"
 	"github.com/houseabsolute/catalauncher/util"
 	"github.com/manifoldco/promptui"
@@ -29,7 +30,7 @@ func New(rootDir, configFile string) (*Setupper, error) {
 }
 
 func (s *Setupper) Setup() error {
-	err := s.checkForDocker()
+	err := s.chooseContainerRuntime()
 	if err != nil {
 		return err
 	}
@@ -42,11 +43,42 @@ func (s *Setupper) Setup() error {
 	return nil
 }
 
-func (s *Setupper) checkForDocker() error {
-	_, err := exec.LookPath("docker")
-	if err != nil {
-		return fmt.Errorf("To use catalauncher you must have docker in your $PATH: %s", err)
+func (s *Setupper) chooseContainerRuntime() error {
+	// Running the game natively is always an option, but it's last since it
+	// requires the game's libraries to be installed on the host.
+	choices := append(container.Detect(), config.NativeRuntime)
+	if len(choices) == 1 {
+		util.Say(
+			os.Stdout,
+			"Could not find %s in your $PATH so the game will be run natively, which requires SDL2 to be installed",
+			strings.Join(container.Names, " or "),
+		)
+	}
+
+	rt := choices[0]
+	if len(choices) > 1 {
+		def := 0
+		for i, c := range choices {
+			if c == viper.GetString("runtime") {
+				def = i
+			}
+		}
+
+		prompt := promptui.Select{
+			Label:     "How should the game be run?",
+			Items:     choices,
+			CursorPos: def,
+		}
+		_, val, err := prompt.Run()
+		if err != nil {
+			return fmt.Errorf("Error attempting to run a prompt: %s", err)
+		}
+		rt = val
 	}
+
+	util.Say(os.Stdout, "Using %s to run the game", rt)
+	viper.Set("runtime", rt)
+
 	return nil
 }
 
@@ -0,0 +1,421 @@
+package snapshotter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/houseabsolute/catalauncher/archiver"
+	"github.com/houseabsolute/catalauncher/config"
+	"github.com/houseabsolute/catalauncher/container"
+	"github.com/houseabsolute/catalauncher/curuser"
+	"github.com/houseabsolute/catalauncher/util"
+)
+
+type Snapshotter struct {
+	config *config.Config
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// Snapshot is a saved copy of either a single world or the whole save
+// directory.
+type Snapshot struct {
+	// Name is what the user passes to restore the snapshot. Automatic backups
+	// have names starting with "auto/".
+	Name string
+	// Auto is true if this snapshot was taken automatically before a launch.
+	Auto bool
+	// World is the name of the world in the snapshot, or an empty string if
+	// the snapshot contains every world.
+	World string
+	Time  time.Time
+	Size  int64
+	file  string
+}
+
+func New(rootDir string) (*Snapshotter, error) {
+	c, err := config.New(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Snapshotter{
+		config: c,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}, nil
+}
+
+const timeFormat = "20060102-150405"
+
+// allWorlds is used in the snapshot name for a snapshot of the whole save
+// dir.
+const allWorlds = "all"
+
+const snapshotExt = ".tar.gz"
+
+// Snapshot makes a snapshot of the given world. If world is empty it makes a
+// snapshot of every world.
+func (s *Snapshotter) Snapshot(world string) (*Snapshot, error) {
+	src, err := s.worldDir(world)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := util.PathExists(src)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if world == "" {
+			return nil, fmt.Errorf("There are no saves to snapshot in %s", src)
+		}
+		return nil, fmt.Errorf("There is no world named %s in %s", world, s.config.SaveDir())
+	}
+
+	return s.snapshot(s.config.SnapshotsDir(), src, world)
+}
+
+// Backup takes a snapshot of every world and then removes the oldest
+// automatic backups so that at most keep of them are left.
+func (s *Snapshotter) Backup(keep int) error {
+	exists, err := util.PathExists(s.config.SaveDir())
+	if err != nil {
+		return err
+	}
+	if !exists {
+		util.Say(s.stdout, "There are no saves to back up yet")
+		return nil
+	}
+
+	_, err = s.snapshot(s.config.AutoBackupsDir(), s.config.SaveDir(), "")
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := s.Snapshots()
+	if err != nil {
+		return err
+	}
+
+	auto := []*Snapshot{}
+	for _, snap := range snapshots {
+		if snap.Auto {
+			auto = append(auto, snap)
+		}
+	}
+
+	// Snapshots are sorted oldest first.
+	for len(auto) > keep {
+		util.Say(s.stdout, "Removing old save backup %s", auto[0].Name)
+		err := os.Remove(auto[0].file)
+		if err != nil {
+			return fmt.Errorf("Could not remove %s: %s", auto[0].file, err)
+		}
+		auto = auto[1:]
+	}
+
+	return nil
+}
+
+func (s *Snapshotter) snapshot(dir, src, world string) (*Snapshot, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("Could not make directory %s: %s", dir, err)
+	}
+
+	label := world
+	if label == "" {
+		label = allWorlds
+	}
+
+	// Snapshot names only have a resolution of one second, so if we take two
+	// in the same second we pretend the second one is a little newer rather
+	// than overwriting the first.
+	now := time.Now()
+	var name, file string
+	for {
+		name = now.Format(timeFormat) + "-" + label
+		file = filepath.Join(dir, name+snapshotExt)
+		exists, err := util.PathExists(file)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			break
+		}
+		now = now.Add(time.Second)
+	}
+
+	util.Say(s.stdout, "Saving a snapshot of %s to %s", src, file)
+	err = archiver.Create(file, src)
+	if err != nil {
+		return nil, err
+	}
+
+	auto := dir == s.config.AutoBackupsDir()
+	if auto {
+		name = autoPrefix + name
+	}
+
+	return &Snapshot{Name: name, Auto: auto, World: world, Time: now, file: file}, nil
+}
+
+const autoPrefix = "auto/"
+
+func (s *Snapshotter) worldDir(world string) (string, error) {
+	if world == "" {
+		return s.config.SaveDir(), nil
+	}
+	if world == "." || world == ".." || strings.ContainsAny(world, `/\`) {
+		return "", fmt.Errorf("%s is not a valid world name", world)
+	}
+	return filepath.Join(s.config.SaveDir(), world), nil
+}
+
+var snapshotRE = regexp.MustCompile(`^(\d{8}-\d{6})-(.+)$`)
+
+// Snapshots returns all the snapshots, including automatic backups, sorted
+// from oldest to newest.
+func (s *Snapshotter) Snapshots() ([]*Snapshot, error) {
+	snapshots, err := s.snapshotsIn(s.config.SnapshotsDir(), false)
+	if err != nil {
+		return nil, err
+	}
+
+	auto, err := s.snapshotsIn(s.config.AutoBackupsDir(), true)
+	if err != nil {
+		return nil, err
+	}
+	snapshots = append(snapshots, auto...)
+
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].Time.Before(snapshots[j].Time) })
+
+	return snapshots, nil
+}
+
+func (s *Snapshotter) snapshotsIn(dir string, auto bool) ([]*Snapshot, error) {
+	snapshots := []*Snapshot{}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return snapshots, nil
+		}
+		return nil, fmt.Errorf("Could not read directory at %s: %s", dir, err)
+	}
+
+	for _, e := range entries {
+		if !e.Mode().IsRegular() || !strings.HasSuffix(e.Name(), snapshotExt) {
+			continue
+		}
+
+		name := strings.TrimSuffix(e.Name(), snapshotExt)
+		m := snapshotRE.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+
+		t, err := time.ParseInLocation(timeFormat, m[1], time.Local)
+		if err != nil {
+			continue
+		}
+
+		world := m[2]
+		if world == allWorlds {
+			world = ""
+		}
+
+		if auto {
+			name = autoPrefix + name
+		}
+
+		snapshots = append(snapshots, &Snapshot{
+			Name:  name,
+			Auto:  auto,
+			World: world,
+			Time:  t,
+			Size:  e.Size(),
+			file:  filepath.Join(dir, e.Name()),
+		})
+	}
+
+	return snapshots, nil
+}
+
+// List prints all the snapshots.
+func (s *Snapshotter) List() error {
+	snapshots, err := s.Snapshots()
+	if err != nil {
+		return err
+	}
+
+	if len(snapshots) == 0 {
+		util.Say(s.stdout, "There are no snapshots yet")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(s.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SNAPSHOT\tWORLD\tTAKEN\tSIZE\t")
+	for _, snap := range snapshots {
+		world := snap.World
+		if world == "" {
+			world = "(all worlds)"
+		}
+		fmt.Fprintf(
+			w, "%s\t%s\t%s\t%d KiB\t\n",
+			snap.Name, world, snap.Time.Format("2006-01-02 15:04:05"), (snap.Size+1023)/1024,
+		)
+	}
+	return w.Flush()
+}
+
+// Restore replaces the world in the snapshot (or all worlds) with the
+// contents of the snapshot. The current state of the world is snapshotted
+// first so that a restore can itself be undone.
+func (s *Snapshotter) Restore(name string) error {
+	snap, err := s.find(name)
+	if err != nil {
+		return err
+	}
+
+	running, err := GameIsRunning(s.config)
+	if err != nil {
+		return err
+	}
+	if running {
+		return errors.New("The game is running. Quit the game before restoring a snapshot")
+	}
+
+	target, err := s.worldDir(snap.World)
+	if err != nil {
+		return err
+	}
+
+	exists, err := util.PathExists(target)
+	if err != nil {
+		return err
+	}
+	if exists {
+		util.Say(s.stdout, "Taking a snapshot of the current state before restoring")
+		_, err := s.Snapshot(snap.World)
+		if err != nil {
+			return err
+		}
+	}
+
+	// We extract into a directory next to the target so that we can rename
+	// the result into place.
+	parent := filepath.Dir(target)
+	err = os.MkdirAll(parent, 0755)
+	if err != nil {
+		return fmt.Errorf("Could not make directory %s: %s", parent, err)
+	}
+	tmp, err := ioutil.TempDir(parent, ".restore-")
+	if err != nil {
+		return fmt.Errorf("Could not create a temporary directory in %s: %s", parent, err)
+	}
+	defer os.RemoveAll(tmp)
+
+	util.Say(s.stdout, "Restoring snapshot %s to %s", snap.Name, target)
+	err = archiver.Extract(snap.file, tmp)
+	if err != nil {
+		return err
+	}
+
+	restored := filepath.Join(tmp, filepath.Base(target))
+	exists, err = util.PathExists(restored)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("The snapshot at %s does not contain %s", snap.file, filepath.Base(target))
+	}
+
+	if exists, _ := util.PathExists(target); exists {
+		err = os.Rename(target, filepath.Join(tmp, "previous"))
+		if err != nil {
+			return fmt.Errorf("Could not move %s out of the way: %s", target, err)
+		}
+	}
+
+	err = os.Rename(restored, target)
+	if err != nil {
+		return fmt.Errorf("Could not move %s to %s: %s", restored, target, err)
+	}
+
+	return nil
+}
+
+func (s *Snapshotter) find(name string) (*Snapshot, error) {
+	snapshots, err := s.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSuffix(name, snapshotExt)
+	for _, snap := range snapshots {
+		if snap.Name == name {
+			return snap, nil
+		}
+	}
+
+	return nil, fmt.Errorf("There is no snapshot named %s in %s", name, s.config.SnapshotsDir())
+}
+
+// GameIsRunning returns true if the launcher's game container is running,
+// or in native mode, if the game binary is running.
+func GameIsRunning(c *config.Config) (bool, error) {
+	if c.Native() {
+		return nativeGameIsRunning()
+	}
+
+	if c.ContainerRuntime() == "" && len(container.Detect()) == 0 {
+		return false, nil
+	}
+
+	rt, err := container.New(c.ContainerRuntime())
+	if err != nil {
+		return false, err
+	}
+
+	// The image may be pinned to an id for the build that was launched, so
+	// we look for the container by its name rather than its image.
+	user, err := curuser.New()
+	if err != nil {
+		return false, err
+	}
+
+	return container.IsRunning(rt, container.GameContainerName(user.Uid))
+}
+
+// The kernel truncates process names in /proc/<pid>/comm to 15 characters,
+// which happens to be exactly the length of the game binary's name.
+func nativeGameIsRunning() (bool, error) {
+	comms, err := filepath.Glob("/proc/[0-9]*/comm")
+	if err != nil {
+		return false, err
+	}
+
+	for _, c := range comms {
+		name, err := ioutil.ReadFile(c)
+		if err != nil {
+			// The process may have exited since we globbed.
+			continue
+		}
+		if strings.TrimSpace(string(name)) == config.GameBinary {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
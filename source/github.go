@@ -0,0 +1,196 @@
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultGitHubAPIURL is the base URL for the GitHub API.
+const DefaultGitHubAPIURL = "https://api.github.com"
+
+// DefaultGitHubRepo is the repo that experimental builds are released from.
+const DefaultGitHubRepo = "CleverRaven/Cataclysm-DDA"
+
+// GitHub finds builds by looking at the releases for a GitHub repo. The base
+// API URL can be changed so that this can be pointed at something other than
+// github.com.
+type GitHub struct {
+	apiURL string
+	repo   string
+}
+
+func NewGitHub(apiURL, repo string) *GitHub {
+	if apiURL == "" {
+		apiURL = DefaultGitHubAPIURL
+	}
+	if repo == "" {
+		repo = DefaultGitHubRepo
+	}
+	return &GitHub{
+		apiURL: strings.TrimSuffix(apiURL, "/"),
+		repo:   repo,
+	}
+}
+
+func (g *GitHub) Name() string {
+	return g.releasesURI()
+}
+
+func (g *GitHub) releasesURI() string {
+	return fmt.Sprintf("%s/repos/%s/releases", g.apiURL, g.repo)
+}
+
+type ghRelease struct {
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	HTMLURL     string    `json:"html_url"`
+	PublishedAt time.Time `json:"published_at"`
+	Draft       bool      `json:"draft"`
+	Assets      []ghAsset `json:"assets"`
+}
+
+type ghAsset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+	Size               int64  `json:"size"`
+	Digest             string `json:"digest"`
+}
+
+// Older releases were tagged with the Jenkins build number. Newer releases
+// are tagged with a date and time, which we turn into a build number like
+// 202101311234 so that build numbers still sort by release time.
+var (
+	jenkinsTagRE      = regexp.MustCompile(`^cdda-jenkins-b(\d+)$`)
+	experimentalTagRE = regexp.MustCompile(`^cdda-experimental-(\d{4})-(\d\d)-(\d\d)-(\d{4})$`)
+)
+
+// We want the Linux tiles build, which has had a few different names over
+// the years.
+var linuxTilesAssetRE = regexp.MustCompile(
+	`^(?:cdda-linux-(?:tiles|with-graphics)-x64-.+|cataclysmdda-\d\.[A-Z]-Linux_x64-Tiles-b?\d+)\.tar\.gz$`,
+)
+
+func (g *GitHub) Builds() ([]Build, error) {
+	// We only look at the first page of releases. There's a new experimental
+	// release just about every day so 100 releases goes back quite a ways.
+	uri := g.releasesURI() + "?per_page=100"
+	body, err := get(uri, map[string]string{"Accept": "application/vnd.github.v3+json"})
+	if err != nil {
+		return []Build{}, err
+	}
+	defer body.Close()
+
+	var releases []ghRelease
+	err = json.NewDecoder(body).Decode(&releases)
+	if err != nil {
+		return []Build{}, fmt.Errorf("Error parsing JSON from %s: %w", uri, err)
+	}
+
+	builds := []Build{}
+	for _, r := range releases {
+		if r.Draft {
+			continue
+		}
+
+		num, err := buildNumberFromTag(r.TagName)
+		if err != nil {
+			return []Build{}, err
+		}
+		if num == 0 {
+			continue
+		}
+
+		sidecars := map[string]string{}
+		for _, a := range r.Assets {
+			if strings.HasSuffix(a.Name, ".sha256") {
+				sidecars[strings.TrimSuffix(a.Name, ".sha256")] = a.BrowserDownloadURL
+			}
+		}
+
+		for _, a := range r.Assets {
+			if !linuxTilesAssetRE.MatchString(a.Name) {
+				continue
+			}
+			builds = append(
+				builds,
+				Build{
+					Number:     num,
+					Version:    r.TagName,
+					Variant:    "linux-tiles-x64",
+					Filename:   a.Name,
+					Date:       r.PublishedAt,
+					uri:        a.BrowserDownloadURL,
+					changesURI: r.HTMLURL,
+					sha256:     sha256Digest(a.Digest),
+					sha256URI:  sidecars[a.Name],
+				},
+			)
+			break
+		}
+	}
+
+	// Using After gives us a reverse sorting from most to least recent.
+	sort.SliceStable(builds, func(i, j int) bool { return builds[i].Date.After(builds[j].Date) })
+	return builds, nil
+}
+
+// Digests look like "sha256:abc123...".
+func sha256Digest(digest string) string {
+	if !strings.HasPrefix(digest, "sha256:") {
+		return ""
+	}
+	return strings.TrimPrefix(digest, "sha256:")
+}
+
+// buildNumberFromTag returns 0 if the tag isn't one that we know how to turn
+// into a build number.
+func buildNumberFromTag(tag string) (uint, error) {
+	var digits string
+	if m := jenkinsTagRE.FindStringSubmatch(tag); m != nil {
+		digits = m[1]
+	} else if m := experimentalTagRE.FindStringSubmatch(tag); m != nil {
+		digits = strings.Join(m[1:], "")
+	} else {
+		return 0, nil
+	}
+
+	num, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Could not convert %s to an integer: %s", digits, err)
+	}
+	return uint(num), nil
+}
+
+func (g *GitHub) Build(num uint) (Build, error) {
+	return findBuild(g, num)
+}
+
+func (g *GitHub) DownloadURL(b Build) string {
+	return b.uri
+}
+
+// GitHub records a digest for each release asset. Older releases don't have
+// this, but they may have a sidecar .sha256 asset instead.
+func (g *GitHub) SHA256(b Build) (string, error) {
+	if b.sha256 != "" {
+		return parseSHA256(b.sha256, g.DownloadURL(b))
+	}
+	if b.sha256URI != "" {
+		return fetchSHA256(b.sha256URI)
+	}
+	return "", nil
+}
+
+func (g *GitHub) Metadata(b Build) Metadata {
+	return Metadata{
+		Source:     g.Name(),
+		Version:    b.Version,
+		Date:       b.Date,
+		ChangesURI: b.changesURI,
+	}
+}
@@ -0,0 +1,274 @@
+package source
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRepo = "CleverRaven/Cataclysm-DDA"
+
+const sidecarSHA256 = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+const digestSHA256 = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+
+// The {{url}} placeholder is replaced with the test server's URL.
+const releasesJSON = `[
+  {
+    "tag_name": "cdda-experimental-2021-02-01-0930",
+    "name": "Draft release",
+    "html_url": "{{url}}/releases/draft",
+    "published_at": "2021-02-01T09:30:00Z",
+    "draft": true,
+    "assets": [
+      {
+        "name": "cdda-linux-tiles-x64-2021-02-01-0930.tar.gz",
+        "browser_download_url": "{{url}}/download/draft.tar.gz"
+      }
+    ]
+  },
+  {
+    "tag_name": "cdda-jenkins-b11000",
+    "name": "Jenkins build",
+    "html_url": "{{url}}/releases/b11000",
+    "published_at": "2020-10-01T12:00:00Z",
+    "draft": false,
+    "assets": [
+      {
+        "name": "cataclysmdda-0.E-Windows_x64-Tiles-b11000.zip",
+        "browser_download_url": "{{url}}/download/b11000-windows.zip"
+      },
+      {
+        "name": "cataclysmdda-0.E-Linux_x64-Tiles-b11000.tar.gz",
+        "browser_download_url": "{{url}}/download/b11000-linux.tar.gz"
+      },
+      {
+        "name": "cataclysmdda-0.E-Linux_x64-Tiles-b11000.tar.gz.sha256",
+        "browser_download_url": "{{url}}/download/b11000-linux.tar.gz.sha256"
+      }
+    ]
+  },
+  {
+    "tag_name": "0.F",
+    "name": "Stable release",
+    "html_url": "{{url}}/releases/0.F",
+    "published_at": "2021-03-01T00:00:00Z",
+    "draft": false,
+    "assets": [
+      {
+        "name": "cdda-linux-tiles-x64-0.F.tar.gz",
+        "browser_download_url": "{{url}}/download/0.F.tar.gz"
+      }
+    ]
+  },
+  {
+    "tag_name": "cdda-experimental-2021-01-31-1234",
+    "name": "Experimental build",
+    "html_url": "{{url}}/releases/2021-01-31-1234",
+    "published_at": "2021-01-31T12:34:00Z",
+    "draft": false,
+    "assets": [
+      {
+        "name": "cdda-windows-tiles-x64-2021-01-31-1234.zip",
+        "browser_download_url": "{{url}}/download/experimental-windows.zip"
+      },
+      {
+        "name": "cdda-linux-curses-x64-2021-01-31-1234.tar.gz",
+        "browser_download_url": "{{url}}/download/experimental-curses.tar.gz"
+      },
+      {
+        "name": "cdda-linux-tiles-x64-2021-01-31-1234.tar.gz",
+        "browser_download_url": "{{url}}/download/experimental-tiles.tar.gz",
+        "digest": "sha256:` + digestSHA256 + `"
+      }
+    ]
+  }
+]`
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	var server *httptest.Server
+	mux.HandleFunc("/repos/"+testRepo+"/releases", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("per_page") != "100" {
+			t.Errorf("expected a per_page=100 query parameter, got %q", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, strings.ReplaceAll(releasesJSON, "{{url}}", server.URL))
+	})
+	mux.HandleFunc("/download/b11000-linux.tar.gz.sha256", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s  cataclysmdda-0.E-Linux_x64-Tiles-b11000.tar.gz\n", strings.ToUpper(sidecarSHA256))
+	})
+
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestGitHubBuilds(t *testing.T) {
+	server := newTestServer(t)
+	g := NewGitHub(server.URL+"/", testRepo)
+
+	builds, err := g.Builds()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The draft release and the release with a tag we can't turn into a
+	// build number are skipped, and the rest are sorted by date.
+	if len(builds) != 2 {
+		t.Fatalf("expected 2 builds, got %d: %+v", len(builds), builds)
+	}
+
+	expect := []struct {
+		number   uint
+		version  string
+		filename string
+		uri      string
+		date     time.Time
+	}{
+		{
+			number:   202101311234,
+			version:  "cdda-experimental-2021-01-31-1234",
+			filename: "cdda-linux-tiles-x64-2021-01-31-1234.tar.gz",
+			uri:      server.URL + "/download/experimental-tiles.tar.gz",
+			date:     time.Date(2021, 1, 31, 12, 34, 0, 0, time.UTC),
+		},
+		{
+			number:   11000,
+			version:  "cdda-jenkins-b11000",
+			filename: "cataclysmdda-0.E-Linux_x64-Tiles-b11000.tar.gz",
+			uri:      server.URL + "/download/b11000-linux.tar.gz",
+			date:     time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC),
+		},
+	}
+	for i, e := range expect {
+		b := builds[i]
+		if b.Number != e.number {
+			t.Errorf("build %d: expected number %d, got %d", i, e.number, b.Number)
+		}
+		if b.Version != e.version {
+			t.Errorf("build %d: expected version %s, got %s", i, e.version, b.Version)
+		}
+		if b.Variant != "linux-tiles-x64" {
+			t.Errorf("build %d: expected the linux-tiles-x64 variant, got %s", i, b.Variant)
+		}
+		if b.Filename != e.filename {
+			t.Errorf("build %d: expected filename %s, got %s", i, e.filename, b.Filename)
+		}
+		if g.DownloadURL(b) != e.uri {
+			t.Errorf("build %d: expected download URL %s, got %s", i, e.uri, g.DownloadURL(b))
+		}
+		if !b.Date.Equal(e.date) {
+			t.Errorf("build %d: expected date %s, got %s", i, e.date, b.Date)
+		}
+	}
+}
+
+func TestGitHubBuild(t *testing.T) {
+	server := newTestServer(t)
+	g := NewGitHub(server.URL, testRepo)
+
+	b, err := g.Build(11000)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if b.Version != "cdda-jenkins-b11000" {
+		t.Errorf("expected version cdda-jenkins-b11000, got %s", b.Version)
+	}
+
+	m := g.Metadata(b)
+	if m.ChangesURI != server.URL+"/releases/b11000" {
+		t.Errorf("expected changes URI %s/releases/b11000, got %s", server.URL, m.ChangesURI)
+	}
+
+	_, err = g.Build(202102010930)
+	if err == nil {
+		t.Error("expected an error asking for the build from a draft release")
+	}
+}
+
+func TestGitHubSHA256(t *testing.T) {
+	server := newTestServer(t)
+	g := NewGitHub(server.URL, testRepo)
+
+	tests := map[uint]string{
+		// This one comes from the asset's digest.
+		202101311234: digestSHA256,
+		// This one comes from the .sha256 sidecar asset.
+		11000: sidecarSHA256,
+	}
+	for num, expect := range tests {
+		b, err := g.Build(num)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		sum, err := g.SHA256(b)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if sum != expect {
+			t.Errorf("build %d: expected SHA-256 %s, got %s", num, expect, sum)
+		}
+	}
+}
+
+func TestBuildNumberFromTag(t *testing.T) {
+	tests := map[string]uint{
+		"cdda-jenkins-b10478":               10478,
+		"cdda-experimental-2021-01-31-1234": 202101311234,
+		"0.F":                               0,
+		"cdda-experimental-2021-01-31":      0,
+		"cdda-jenkins-b":                    0,
+	}
+	for tag, expect := range tests {
+		num, err := buildNumberFromTag(tag)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tag, err)
+			continue
+		}
+		if num != expect {
+			t.Errorf("%s: expected %d, got %d", tag, expect, num)
+		}
+	}
+}
+
+func TestGitHubNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	_, err := NewGitHub(url, testRepo).Builds()
+	var netErr *NetworkError
+	if !errors.As(err, &netErr) {
+		t.Fatalf("expected a NetworkError, got %v", err)
+	}
+	if !strings.HasPrefix(netErr.URI, url+"/repos/"+testRepo+"/releases") {
+		t.Errorf("expected the error to be for the releases URI, got %s", netErr.URI)
+	}
+}
+
+func TestGitHubErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	_, err := NewGitHub(server.URL, testRepo).Builds()
+	if err == nil {
+		t.Fatal("expected an error for a 403 response")
+	}
+
+	// The server was reachable, so this should not look like a network
+	// problem.
+	var netErr *NetworkError
+	if errors.As(err, &netErr) {
+		t.Errorf("did not expect a NetworkError for a 403 response, got %s", err)
+	}
+}
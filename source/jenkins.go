@@ -0,0 +1,131 @@
+package source
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// DefaultJenkinsURI is the directory listing that the Jenkins builds were
+// historically published to.
+const DefaultJenkinsURI = "http://dev.narc.ro/cataclysm/jenkins-latest/Linux_x64/Tiles/"
+
+const jenkinsChangesURI = "http://gorgon.narc.ro:8080/job/Cataclysm-Matrix/changes"
+
+// Jenkins finds builds by scraping the HTML directory listing that the CDDA
+// Jenkins server publishes builds to.
+type Jenkins struct {
+	buildsURI string
+}
+
+func NewJenkins(buildsURI string) *Jenkins {
+	if buildsURI == "" {
+		buildsURI = DefaultJenkinsURI
+	}
+	return &Jenkins{buildsURI: buildsURI}
+}
+
+func (j *Jenkins) Name() string {
+	return j.buildsURI
+}
+
+var fileRE = regexp.MustCompile(`^cataclysmdda-([0-9].[A-Z]-Linux_x64-Tiles-(\d+))\.tar\.gz$`)
+
+func (j *Jenkins) Builds() ([]Build, error) {
+	body, err := get(j.buildsURI, nil)
+	if err != nil {
+		return []Build{}, err
+	}
+	defer body.Close()
+
+	doc, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return []Build{}, fmt.Errorf("Error parsing HTML from %s: %w", j.buildsURI, err)
+	}
+
+	buildDates, err := j.parseBuildDates(doc)
+	if err != nil {
+		return []Build{}, err
+	}
+
+	builds := []Build{}
+	var eachErr error
+	doc.Find("a").Each(func(_ int, sel *goquery.Selection) {
+		if eachErr != nil {
+			return
+		}
+
+		href, _ := sel.Attr("href")
+		m := fileRE.FindStringSubmatch(href)
+		if len(m) < 2 {
+			return
+		}
+
+		num, err := strconv.Atoi(m[2])
+		if err != nil {
+			eachErr = fmt.Errorf("Could not convert %s to an integer: %s", m[2], err)
+		}
+
+		builds = append(
+			builds,
+			Build{
+				Number:     uint(num),
+				Version:    m[1],
+				Variant:    "Linux_x64-Tiles",
+				Filename:   href,
+				Date:       buildDates[href],
+				uri:        j.buildsURI + href,
+				changesURI: jenkinsChangesURI,
+			},
+		)
+	})
+	if eachErr != nil {
+		return []Build{}, eachErr
+	}
+
+	// Using After gives us a reverse sorting from most to least recent.
+	sort.SliceStable(builds, func(i, j int) bool { return builds[i].Date.After(builds[j].Date) })
+	return builds, nil
+}
+
+var buildDatesRE = regexp.MustCompile(`(cataclysmdda-\S+\.tar\.gz)\s+(2\d\d\d-\d\d-\d\d \d\d:\d\d)`)
+
+func (j *Jenkins) parseBuildDates(doc *goquery.Document) (map[string]time.Time, error) {
+	dates := map[string]time.Time{}
+	m := buildDatesRE.FindAllStringSubmatch(doc.Find("body").First().Text(), -1)
+	for _, pair := range m {
+		d, err := time.Parse("2006-01-02 15:04", pair[2])
+		if err != nil {
+			return dates, fmt.Errorf("Could not parse date for the file %s from text (%s)", pair[1], pair[2])
+		}
+		dates[pair[1]] = d
+	}
+	return dates, nil
+}
+
+func (j *Jenkins) Build(num uint) (Build, error) {
+	return findBuild(j, num)
+}
+
+func (j *Jenkins) DownloadURL(b Build) string {
+	return b.uri
+}
+
+// Jenkins never published checksums, but a mirror of its directory listing
+// might, so we look for a sidecar file next to the archive.
+func (j *Jenkins) SHA256(b Build) (string, error) {
+	return fetchSHA256(b.uri + ".sha256")
+}
+
+func (j *Jenkins) Metadata(b Build) Metadata {
+	return Metadata{
+		Source:     j.Name(),
+		Version:    b.Version,
+		Date:       b.Date,
+		ChangesURI: b.changesURI,
+	}
+}
@@ -0,0 +1,235 @@
+package source
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/houseabsolute/catalauncher/config"
+)
+
+// Build is a single build that a BuildSource knows how to download.
+type Build struct {
+	Number   uint
+	Version  string
+	Variant  string
+	Filename string
+	Date     time.Time
+
+	uri        string
+	changesURI string
+	sha256     string
+	sha256URI  string
+}
+
+// Metadata is extra information about a build that isn't needed to download
+// it but which is nice to show to the user.
+type Metadata struct {
+	Source     string
+	Version    string
+	Date       time.Time
+	ChangesURI string
+}
+
+// BuildSource is something that can tell us what builds exist and where to
+// download them from.
+type BuildSource interface {
+	// Name returns a description of the source suitable for showing to the
+	// user.
+	Name() string
+	// Builds returns all the available builds, sorted from most to least
+	// recent.
+	Builds() ([]Build, error)
+	// Build returns the build with the given number, or an error if no such
+	// build exists.
+	Build(num uint) (Build, error)
+	// DownloadURL returns the URL of the archive for the given build.
+	DownloadURL(b Build) string
+	// Metadata returns the metadata for the given build.
+	Metadata(b Build) Metadata
+	// SHA256 returns the hex-encoded SHA-256 digest of the build's archive,
+	// or an empty string if the source doesn't publish one.
+	SHA256(b Build) (string, error)
+}
+
+// New returns the BuildSource selected in the config.
+func New(c *config.Config) (BuildSource, error) {
+	switch c.BuildSource() {
+	case "github":
+		return NewGitHub(c.BuildSourceURL(), c.BuildSourceRepo()), nil
+	case "jenkins":
+		return NewJenkins(c.BuildSourceURL()), nil
+	default:
+		return nil, fmt.Errorf(`Unknown build source type "%s", must be one of "github" or "jenkins"`, c.BuildSource())
+	}
+}
+
+// Client is the HTTP client used to fetch build lists and builds. It has no
+// overall timeout, since downloading a build over a slow connection can take
+// a long time, but it gives up if it can't connect or if the server doesn't
+// start responding. Response bodies should be wrapped with IdleTimeout so
+// that a connection that stops sending data partway through doesn't hang
+// forever.
+var Client = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+	},
+}
+
+// ReadTimeout is how long reading a response body can go without getting any
+// data before we give up on the connection.
+const ReadTimeout = 60 * time.Second
+
+type idleTimeoutBody struct {
+	uri     string
+	body    io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	expired int32
+}
+
+// IdleTimeout wraps a response body so that a read which gets no data for
+// timeout returns a NetworkError. The body is closed when the timeout
+// expires, which makes the blocked read return.
+func IdleTimeout(uri string, body io.ReadCloser, timeout time.Duration) io.ReadCloser {
+	b := &idleTimeoutBody{uri: uri, body: body, timeout: timeout}
+	b.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&b.expired, 1)
+		body.Close()
+	})
+	return b
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	if !b.timer.Stop() || atomic.LoadInt32(&b.expired) == 1 {
+		return 0, b.timeoutError()
+	}
+	b.timer.Reset(b.timeout)
+
+	n, err := b.body.Read(p)
+	if err != nil && atomic.LoadInt32(&b.expired) == 1 {
+		return n, b.timeoutError()
+	}
+	return n, err
+}
+
+func (b *idleTimeoutBody) timeoutError() error {
+	return &NetworkError{
+		URI: b.uri,
+		Err: fmt.Errorf("no data was received for %s", b.timeout),
+	}
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	return b.body.Close()
+}
+
+// NetworkError is returned when a request could not be made at all, as
+// opposed to a request that got an error response. This lets callers tell
+// when the network is unreachable.
+type NetworkError struct {
+	URI string
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("Could not fetch %s: %s", e.URI, e.Err)
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+func findBuild(s BuildSource, num uint) (Build, error) {
+	builds, err := s.Builds()
+	if err != nil {
+		return Build{}, err
+	}
+
+	for _, b := range builds {
+		if b.Number == num {
+			return b, nil
+		}
+	}
+
+	return Build{}, fmt.Errorf(
+		"Could not find the build you requested, #%d, in the list of available builds", num)
+}
+
+// fetchSHA256 fetches a sidecar checksum file like the ones that sha256sum
+// generates. If the file doesn't exist it returns an empty string.
+func fetchSHA256(uri string) (string, error) {
+	res, err := Client.Get(uri)
+	if err != nil {
+		return "", &NetworkError{URI: uri, Err: err}
+	}
+	body := IdleTimeout(uri, res.Body, ReadTimeout)
+	defer body.Close()
+
+	if res.StatusCode == 404 {
+		return "", nil
+	}
+	if res.StatusCode != 200 {
+		return "", fmt.Errorf(
+			"Did not get a 200 status when fetching %s, got a %d (%s) instead",
+			uri, res.StatusCode, res.Status,
+		)
+	}
+
+	// Checksum files are tiny, so anything bigger than this is not what we're
+	// looking for.
+	content, err := ioutil.ReadAll(io.LimitReader(body, 4096))
+	if err != nil {
+		return "", fmt.Errorf("Could not read checksum from %s: %w", uri, err)
+	}
+
+	return parseSHA256(string(content), uri)
+}
+
+var sha256RE = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+func parseSHA256(text, from string) (string, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !sha256RE.MatchString(fields[0]) {
+		return "", fmt.Errorf("Could not find a SHA-256 checksum in %s", from)
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+func get(uri string, headers map[string]string) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Could not make a request for %s: %s", uri, err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := Client.Do(req)
+	if err != nil {
+		return nil, &NetworkError{URI: uri, Err: err}
+	}
+	if res.StatusCode != 200 {
+		res.Body.Close()
+		return nil, fmt.Errorf(
+			"Did not get a 200 status when fetching %s, got a %d (%s) instead",
+			uri, res.StatusCode, res.Status,
+		)
+	}
+
+	return IdleTimeout(uri, res.Body, ReadTimeout), nil
+}
@@ -0,0 +1,80 @@
+package source
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIdleTimeout(t *testing.T) {
+	stop := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1000")
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		// Stall partway through the body.
+		<-stop
+	}))
+	defer server.Close()
+	defer close(stop)
+
+	res, err := Client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	body := IdleTimeout(server.URL, res.Body, 100*time.Millisecond)
+	defer body.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ioutil.ReadAll(body)
+		done <- err
+	}()
+
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reading a stalled body did not time out")
+	}
+
+	var netErr *NetworkError
+	if !errors.As(err, &netErr) {
+		t.Fatalf("expected a NetworkError, got %v", err)
+	}
+	if netErr.URI != server.URL {
+		t.Errorf("expected the error to be for %s, got %s", server.URL, netErr.URI)
+	}
+}
+
+func TestIdleTimeoutSlowBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Each chunk arrives within the timeout, even though the whole body
+		// takes longer than that.
+		for i := 0; i < 5; i++ {
+			w.Write([]byte("chunk"))
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	res, err := Client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	body := IdleTimeout(server.URL, res.Body, 150*time.Millisecond)
+	defer body.Close()
+
+	content, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(content) != 25 {
+		t.Errorf("expected 25 bytes, got %d", len(content))
+	}
+}
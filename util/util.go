@@ -1,9 +1,13 @@
 package util
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
 func PrintErrorAndExit(tmpl string, args ...interface{}) {
@@ -33,3 +37,65 @@ func PathExists(path string) (bool, error) {
 	}
 	return true, err
 }
+
+// FileSHA256 returns the hex-encoded SHA-256 digest of the file at path.
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("Could not open %s: %s", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", fmt.Errorf("Could not read %s: %s", path, err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// WriteFileAtomically writes content to a temporary file next to file and then
+// renames it into place, so that readers never see a partially written file.
+// If file already exists its permissions are preserved.
+func WriteFileAtomically(file string, content []byte) error {
+	dir := filepath.Dir(file)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return fmt.Errorf("Could not make directory %s: %s", dir, err)
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(file)+"-")
+	if err != nil {
+		return fmt.Errorf("Could not create a temporary file in %s: %s", dir, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(content)
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("Could not write %s: %s", tmp.Name(), err)
+	}
+
+	// TempFile creates files that only the owner can read.
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(file); err == nil {
+		mode = info.Mode().Perm()
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("Could not stat %s: %s", file, err)
+	}
+	err = os.Chmod(tmp.Name(), mode)
+	if err != nil {
+		return fmt.Errorf("Could not set the permissions on %s: %s", tmp.Name(), err)
+	}
+
+	err = os.Rename(tmp.Name(), file)
+	if err != nil {
+		return fmt.Errorf("Could not move %s to %s: %s", tmp.Name(), file, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package verifier
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/houseabsolute/catalauncher/config"
+	"github.com/houseabsolute/catalauncher/localbuilds"
+	"github.com/houseabsolute/catalauncher/util"
+)
+
+type Verifier struct {
+	config *config.Config
+	local  *localbuilds.LocalBuilds
+	builds []uint
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func New(rootDir string, builds []uint) (*Verifier, error) {
+	c, err := config.New(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Verifier{
+		config: c,
+		local:  localbuilds.New(c),
+		builds: builds,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}, nil
+}
+
+// Verify checks the files in each build against the checksums that were
+// recorded when the build was installed. If no builds were given then all
+// local builds are checked.
+func (v *Verifier) Verify() error {
+	builds := v.builds
+	if len(builds) == 0 {
+		all, err := v.local.All()
+		if err != nil {
+			return err
+		}
+		builds = all
+	}
+
+	if len(builds) == 0 {
+		util.Say(v.stdout, "There are no builds to verify")
+		return nil
+	}
+
+	failed := []uint{}
+	for _, b := range builds {
+		ok, err := v.verifyBuild(b)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			failed = append(failed, b)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("Verification failed for %d build(s): %v", len(failed), failed)
+	}
+
+	return nil
+}
+
+func (v *Verifier) verifyBuild(b uint) (bool, error) {
+	exists, err := v.local.HasBuild(b)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, fmt.Errorf("Build #%d is not installed", b)
+	}
+
+	dir := v.config.BuildDir(b)
+	m, err := localbuilds.ReadManifest(dir)
+	if err != nil {
+		return false, err
+	}
+	if m == nil || len(m.Files) == 0 {
+		util.Say(v.stdout, "No checksums were recorded when build #%d was installed, skipping it", b)
+		return true, nil
+	}
+
+	util.Say(v.stdout, "Verifying %d files in build #%d (from %s, SHA-256 %s)", len(m.Files), b, m.Archive, m.ArchiveSHA256)
+
+	paths := make([]string, 0, len(m.Files))
+	for p := range m.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	ok := true
+	for _, p := range paths {
+		file := filepath.Join(dir, filepath.FromSlash(p))
+		exists, err := util.PathExists(file)
+		if err != nil {
+			return false, err
+		}
+		if !exists {
+			util.Say(v.stderr, "  missing: %s", p)
+			ok = false
+			continue
+		}
+
+		sum, err := util.FileSHA256(file)
+		if err != nil {
+			return false, err
+		}
+		if sum != m.Files[p] {
+			util.Say(v.stderr, "  modified: %s", p)
+			ok = false
+		}
+	}
+
+	if ok {
+		util.Say(v.stdout, "Build #%d is intact", b)
+	} else {
+		util.Say(v.stderr, "Build #%d does not match the files it was installed with", b)
+	}
+
+	return ok, nil
+}